@@ -0,0 +1,149 @@
+package zhipu
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultAgentMaxSteps = 10
+
+var ErrMaxStepsExceeded = errors.New("agent exceeded max steps without a final answer")
+
+// ToolResult 一次工具调用的执行结果.
+type ToolResult struct {
+	Call     ToolCall
+	Output   string
+	Err      error
+	Duration time.Duration
+}
+
+// AgentStep 一轮模型调用及其触发的工具调用.
+type AgentStep struct {
+	Step        int
+	Message     ChatCompletionMessage
+	ToolResults []ToolResult
+	Usage       Usage
+}
+
+// AgentResult 最终回答以及完整的对话和步骤记录.
+type AgentResult struct {
+	Message  ChatCompletionMessage
+	Messages []ChatCompletionMessage
+	Steps    []AgentStep
+}
+
+// Agent 循环调用模型并执行其请求的工具, 直到模型给出最终回答.
+type Agent struct {
+	Client ChatCompletion[ChatCompletionRequest]
+	Tools  *ToolRegistry
+	// Request 每轮请求使用的模板, Messages 与 Tools 会被覆盖.
+	Request ChatCompletionRequest
+	// MaxSteps 最多调用模型的轮数.
+	MaxSteps int
+	// ToolTimeout 工具的默认执行超时, 0 表示不限制.
+	ToolTimeout time.Duration
+	// OnStep 每轮结束后回调, 可用于输出执行轨迹.
+	OnStep func(step AgentStep)
+}
+
+func NewAgent(client ChatCompletion[ChatCompletionRequest], tools *ToolRegistry, model string) *Agent {
+	return &Agent{
+		Client:   client,
+		Tools:    tools,
+		Request:  ChatCompletionRequest{Model: model},
+		MaxSteps: defaultAgentMaxSteps,
+	}
+}
+
+// RunAgent 从 conversation 开始执行, 同一轮中的多个工具调用并行执行,
+// 工具错误会作为结果内容返回给模型; 超过 MaxSteps 仍未得到最终回答时返回 ErrMaxStepsExceeded.
+func (a *Agent) RunAgent(ctx context.Context, conversation []ChatCompletionMessage) (*AgentResult, error) {
+	maxSteps := a.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = defaultAgentMaxSteps
+	}
+
+	result := &AgentResult{
+		Messages: append([]ChatCompletionMessage(nil), conversation...),
+	}
+
+	for step := 1; step <= maxSteps; step++ {
+		request := a.Request
+		request.Messages = result.Messages
+		if a.Tools != nil {
+			request.Tools = append(append([]Tool(nil), request.Tools...), a.Tools.Tools()...)
+		}
+
+		resp, err := a.Client.CreateChatCompletion(ctx, request)
+		if err != nil {
+			return result, err
+		}
+		if len(resp.Choices) == 0 {
+			return result, ErrNoChoices
+		}
+
+		message := resp.Choices[0].Message
+		if message.Role == "" {
+			message.Role = ChatMessageRoleAssistant
+		}
+		result.Messages = append(result.Messages, message)
+
+		agentStep := AgentStep{Step: step, Message: message, Usage: resp.Usage}
+		if len(message.ToolCalls) > 0 {
+			agentStep.ToolResults = a.runTools(ctx, message.ToolCalls)
+			for _, res := range agentStep.ToolResults {
+				content := res.Output
+				if res.Err != nil {
+					content = "error: " + res.Err.Error()
+				}
+				result.Messages = append(result.Messages, ChatCompletionMessage{
+					Role:       ChatMessageRoleTool,
+					Content:    content,
+					ToolCallID: res.Call.ID,
+				})
+			}
+		}
+
+		result.Steps = append(result.Steps, agentStep)
+		if a.OnStep != nil {
+			a.OnStep(agentStep)
+		}
+
+		if len(message.ToolCalls) == 0 {
+			result.Message = message
+			return result, nil
+		}
+		if err = ctx.Err(); err != nil {
+			return result, err
+		}
+	}
+
+	return result, ErrMaxStepsExceeded
+}
+
+func (a *Agent) runTools(ctx context.Context, calls []ToolCall) []ToolResult {
+	results := make([]ToolResult, len(calls))
+
+	var wg sync.WaitGroup
+	for i := range calls {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			start := time.Now()
+			res := ToolResult{Call: calls[i]}
+			if a.Tools == nil {
+				res.Err = ErrToolNotFound
+			} else {
+				res.Output, res.Err = a.Tools.Call(ctx, calls[i], a.ToolTimeout)
+			}
+			res.Duration = time.Since(start)
+			results[i] = res
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,130 @@
+package zhipu
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type BackendKind int
+
+const (
+	// BackendZhipuV3 智谱 v3 model-api 接口, 即 /{model}/invoke 与 /{model}/sse-invoke.
+	BackendZhipuV3 BackendKind = iota
+	// BackendZhipuV4 智谱 v4 接口, 兼容 OpenAI 的 /chat/completions.
+	BackendZhipuV4
+	// BackendOpenAI 任意兼容 OpenAI 的接口.
+	BackendOpenAI
+)
+
+const (
+	defaultBackendName = "zhipu"
+	bearerPrefix       = "Bearer "
+)
+
+// Backend 一个可以提供对话补全的上游服务.
+type Backend struct {
+	Name string
+	Kind BackendKind
+	// BaseURL 为空时智谱后端使用对应版本的默认地址, 需以 "/" 结尾.
+	BaseURL string
+	// AuthToken 为空时智谱后端使用客户端的 token 或密钥池签名.
+	AuthToken string
+	// Models 请求中的模型名到该后端模型名的映射, 未配置的模型原样使用.
+	Models map[string]string
+}
+
+func (b Backend) model(model string) string {
+	if m, ok := b.Models[model]; ok {
+		return m
+	}
+	return model
+}
+
+func (b Backend) baseURL() string {
+	if b.BaseURL != "" {
+		return b.BaseURL
+	}
+	if b.Kind == BackendZhipuV4 {
+		return glmaiAPIURLv4
+	}
+	return glmaiAPIURLv1
+}
+
+// backends 返回按顺序尝试的后端, 未配置时只有客户端自身的智谱 v3 后端.
+func (c *Client) backends() []Backend {
+	if len(c.config.Backends) > 0 {
+		return c.config.Backends
+	}
+	return []Backend{{
+		Name:    defaultBackendName,
+		Kind:    BackendZhipuV3,
+		BaseURL: c.config.BaseURL,
+	}}
+}
+
+// setBackendAuth 设置后端的鉴权头, 兼容 OpenAI 的后端使用 Bearer 方式.
+// 后端配置了 AuthToken 或为第三方 OpenAI 后端时使用自身的凭证, 不由密钥池签名.
+func (c *Client) setBackendAuth(req *http.Request, backend Backend) {
+	if backend.AuthToken != "" || backend.Kind == BackendOpenAI {
+		*req = *req.WithContext(context.WithValue(req.Context(), explicitAuthKey{}, true))
+	}
+
+	token := backend.AuthToken
+	if token == "" && backend.Kind != BackendOpenAI {
+		token = c.config.authToken
+	}
+	if backend.Kind == BackendZhipuV3 {
+		req.Header.Set("Authorization", token)
+		return
+	}
+	req.Header.Set("Authorization", bearerPrefix+token)
+}
+
+type explicitAuthKey struct{}
+
+// hasExplicitAuth 请求是否使用后端自身的凭证.
+func hasExplicitAuth(req *http.Request) bool {
+	explicit, _ := req.Context().Value(explicitAuthKey{}).(bool)
+	return explicit
+}
+
+// reportBackendError 通知后端调用失败, 返回是否应切换到下一个后端.
+func (c *Client) reportBackendError(ctx context.Context, backend Backend, err error) bool {
+	if c.config.OnBackendError != nil {
+		c.config.OnBackendError(backend.Name, err)
+	}
+	return ctx.Err() == nil && isRetryableError(err)
+}
+
+// isRetryableError 判断错误是否为上游暂时不可用, 可以换一个后端或稍后重试.
+func isRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, ErrNoAvailableKey) || errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	code, status := errorCodes(err)
+	if status == http.StatusTooManyRequests || status >= http.StatusInternalServerError {
+		return true
+	}
+	// 1234 网络错误.
+	return code == 1234 || (zhipuQuotaErrorCodes[code] && code != 1304)
+}
+
+// authScheme 返回请求当前使用的鉴权前缀, 密钥池签名时保持不变.
+func authScheme(req *http.Request) string {
+	if strings.HasPrefix(req.Header.Get("Authorization"), bearerPrefix) {
+		return bearerPrefix
+	}
+	return ""
+}
@@ -0,0 +1,310 @@
+package zhipu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultCompletionEstimate 预留额度时估算的输出 tokens.
+const defaultCompletionEstimate = 512
+
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+type BudgetPeriod string
+
+const (
+	BudgetDaily   BudgetPeriod = "daily"
+	BudgetMonthly BudgetPeriod = "monthly"
+)
+
+// BudgetExceededError 租户在某个周期内的 tokens 或费用额度不足, errors.Is(err, ErrBudgetExceeded) 为 true.
+type BudgetExceededError struct {
+	Tenant string
+	Period BudgetPeriod
+	// Resource 为 "tokens" 或 "cost".
+	Resource  string
+	Limit     float64
+	Used      float64
+	Requested float64
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("%s: tenant %s %s %s limit %g, used %g, requested %g",
+		ErrBudgetExceeded, e.Tenant, e.Period, e.Resource, e.Limit, e.Used, e.Requested)
+}
+
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// Budget 租户额度, 为 0 的项不限制; 费用单位为人民币元.
+type Budget struct {
+	DailyTokens   int64
+	MonthlyTokens int64
+	DailyCost     float64
+	MonthlyCost   float64
+}
+
+// TenantUsage 租户当前周期内已使用(含预留)的额度.
+type TenantUsage struct {
+	DailyTokens   int64
+	MonthlyTokens int64
+	DailyCost     float64
+	MonthlyCost   float64
+}
+
+type tenantState struct {
+	day   string
+	month string
+	usage TenantUsage
+}
+
+type tenantKey struct{}
+
+// WithTenant 返回携带租户 ID 的 context, 配置了 BudgetEnforcer 时该租户的调用受额度限制.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext 返回 context 中的租户 ID.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// BudgetEnforcer 按租户限制每日与每月的 tokens 和费用.
+// 调用前按估算用量预留额度, 额度不足时返回 BudgetExceededError, 调用结束后按实际用量结算.
+type BudgetEnforcer struct {
+	mu            sync.Mutex
+	pricing       PricingTable
+	budgets       map[string]Budget
+	defaultBudget *Budget
+	tenants       map[string]*tenantState
+
+	// CompletionEstimate 预留额度时估算的输出 tokens.
+	CompletionEstimate int
+	// Location 划分日、月周期使用的时区, 默认为 time.Local.
+	Location *time.Location
+	now      func() time.Time
+}
+
+// NewBudgetEnforcer 使用价格表创建额度控制, pricing 为 nil 时使用 DefaultPricing.
+func NewBudgetEnforcer(pricing PricingTable) *BudgetEnforcer {
+	if pricing == nil {
+		pricing = DefaultPricing()
+	}
+	return &BudgetEnforcer{
+		pricing:            pricing,
+		budgets:            make(map[string]Budget),
+		tenants:            make(map[string]*tenantState),
+		CompletionEstimate: defaultCompletionEstimate,
+		Location:           time.Local,
+		now:                time.Now,
+	}
+}
+
+// SetBudget 设置租户额度.
+func (b *BudgetEnforcer) SetBudget(tenant string, budget Budget) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.budgets[tenant] = budget
+}
+
+// SetDefaultBudget 设置没有单独配置额度的租户使用的额度.
+func (b *BudgetEnforcer) SetDefaultBudget(budget Budget) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.defaultBudget = &budget
+}
+
+// Usage 返回租户当前周期内已使用(含预留)的额度.
+func (b *BudgetEnforcer) Usage(tenant string) TenantUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state(tenant).usage
+}
+
+// state 返回租户状态, 进入新的日或月时清零对应用量, 调用方需持有锁.
+func (b *BudgetEnforcer) state(tenant string) *tenantState {
+	now := b.now().In(b.Location)
+	day, month := now.Format("2006-01-02"), now.Format("2006-01")
+
+	s, ok := b.tenants[tenant]
+	if !ok {
+		s = &tenantState{day: day, month: month}
+		b.tenants[tenant] = s
+	}
+	if s.month != month {
+		s.month = month
+		s.usage.MonthlyTokens, s.usage.MonthlyCost = 0, 0
+	}
+	if s.day != day {
+		s.day = day
+		s.usage.DailyTokens, s.usage.DailyCost = 0, 0
+	}
+	return s
+}
+
+// reserve 检查并预留 tokens 与费用.
+func (b *BudgetEnforcer) reserve(tenant, model string, tokens int64) (*budgetReservation, error) {
+	cost, _ := b.pricing.Cost(model, Usage{TotalTokens: int(tokens)}, 0)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	budget, ok := b.budgets[tenant]
+	if !ok && b.defaultBudget != nil {
+		budget, ok = *b.defaultBudget, true
+	}
+
+	s := b.state(tenant)
+	if ok {
+		if err := checkBudget(tenant, budget, s.usage, tokens, cost); err != nil {
+			return nil, err
+		}
+	}
+
+	s.apply(tokens, cost)
+	return &budgetReservation{
+		enforcer: b,
+		tenant:   tenant,
+		model:    model,
+		day:      s.day,
+		month:    s.month,
+		tokens:   tokens,
+		cost:     cost,
+	}, nil
+}
+
+func checkBudget(tenant string, budget Budget, used TenantUsage, tokens int64, cost float64) error {
+	checks := []struct {
+		period   BudgetPeriod
+		resource string
+		limit    float64
+		used     float64
+		request  float64
+	}{
+		{BudgetDaily, "tokens", float64(budget.DailyTokens), float64(used.DailyTokens), float64(tokens)},
+		{BudgetMonthly, "tokens", float64(budget.MonthlyTokens), float64(used.MonthlyTokens), float64(tokens)},
+		{BudgetDaily, "cost", budget.DailyCost, used.DailyCost, cost},
+		{BudgetMonthly, "cost", budget.MonthlyCost, used.MonthlyCost, cost},
+	}
+	for _, c := range checks {
+		if c.limit > 0 && c.used+c.request > c.limit {
+			return &BudgetExceededError{
+				Tenant:    tenant,
+				Period:    c.period,
+				Resource:  c.resource,
+				Limit:     c.limit,
+				Used:      c.used,
+				Requested: c.request,
+			}
+		}
+	}
+	return nil
+}
+
+func (s *tenantState) apply(tokens int64, cost float64) {
+	s.usage.DailyTokens += tokens
+	s.usage.MonthlyTokens += tokens
+	s.usage.DailyCost += cost
+	s.usage.MonthlyCost += cost
+}
+
+// applyPeriod 只调整仍是 day 与 month 的周期, 已经结束的周期不再变化.
+func (s *tenantState) applyPeriod(day, month string, tokens int64, cost float64) {
+	if s.day == day {
+		s.usage.DailyTokens += tokens
+		s.usage.DailyCost += cost
+	}
+	if s.month == month {
+		s.usage.MonthlyTokens += tokens
+		s.usage.MonthlyCost += cost
+	}
+}
+
+// budgetReservation 一次调用预留的额度, 只结算一次, nil 表示无需结算.
+type budgetReservation struct {
+	enforcer *BudgetEnforcer
+	tenant   string
+	// model 预留时计价的模型, 结算使用同一模型, 不受后端模型映射影响.
+	model string
+	// day 与 month 预留所在的周期, 结算只调整这两个周期.
+	day    string
+	month  string
+	tokens int64
+	cost   float64
+	once   sync.Once
+}
+
+// settle 用实际用量替换预留额度, err 不为空时释放预留.
+// 成功但没有返回用量(部分兼容 OpenAI 的后端)时与流一样保留预留额度.
+func (r *budgetReservation) settle(usage Usage, err error) {
+	if r == nil {
+		return
+	}
+	if err == nil && usage.TotalTokens == 0 {
+		r.keep()
+		return
+	}
+	r.once.Do(func() {
+		var cost float64
+		if err == nil {
+			cost, _ = r.enforcer.pricing.Cost(r.model, usage, 0)
+		} else {
+			usage = Usage{}
+		}
+
+		r.enforcer.mu.Lock()
+		defer r.enforcer.mu.Unlock()
+		r.enforcer.state(r.tenant).applyPeriod(r.day, r.month, int64(usage.TotalTokens)-r.tokens, cost-r.cost)
+	})
+}
+
+// keep 保留预留的额度作为实际用量, 用于未返回用量就结束的流.
+func (r *budgetReservation) keep() {
+	if r != nil {
+		r.once.Do(func() {})
+	}
+}
+
+// reserveBudget 请求属于某个租户且配置了 BudgetEnforcer 时按估算用量预留额度.
+func (c *Client) reserveBudget(ctx context.Context, request ChatCompletionRequest) (*budgetReservation, error) {
+	tenant, ok := TenantFromContext(ctx)
+	if c.config.Budget == nil || !ok {
+		return nil, nil
+	}
+	return c.config.Budget.reserve(tenant, request.Model, c.config.Budget.estimateTokens(request))
+}
+
+// estimateTokens 粗略估算请求的 tokens: 输入按字符数计算, 输出按 MaxTokens 计算, 未设置时按 CompletionEstimate 计算.
+func (b *BudgetEnforcer) estimateTokens(request ChatCompletionRequest) int64 {
+	var tokens int
+	for _, msg := range request.Messages {
+		tokens += utf8.RuneCountInString(msg.Content)
+		for _, part := range msg.MultiContent {
+			tokens += utf8.RuneCountInString(part.Text)
+		}
+	}
+	if request.MaxTokens > 0 {
+		return int64(tokens + request.MaxTokens)
+	}
+	return int64(tokens + b.CompletionEstimate)
+}
+
+// settleStream 在流返回用量时结算, 未返回用量就关闭的流保留预留额度.
+func (r *budgetReservation) settleStream(stream *GlmChatCompletionStream) {
+	if r == nil {
+		return
+	}
+	stream.observe(func(response GlmChatCompletionStreamResponse, err error) {
+		if err == nil && response.Meta.Usage.TotalTokens > 0 {
+			r.settle(Usage{TotalTokens: response.Meta.Usage.TotalTokens}, nil)
+		}
+	})
+	stream.onClose = append(stream.onClose, r.keep)
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type fileEntry struct {
+	Expires time.Time `json:"expires"`
+	Value   []byte    `json:"value"`
+}
+
+// FileStore 以目录中的文件保存缓存, 每个键一个文件, 进程重启后仍然有效.
+// 键应为文件名安全的字符串, 例如客户端生成的十六进制哈希.
+type FileStore struct {
+	dir string
+	now func() time.Time
+}
+
+var _ Store = (*FileStore)(nil)
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, now: time.Now}, nil
+}
+
+func (s *FileStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	path := s.path(key)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var entry fileEntry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return nil, false, err
+	}
+	if expired(s.now(), entry.Expires) {
+		_ = os.Remove(path)
+		return nil, false, nil
+	}
+	return entry.Value, true, nil
+}
+
+// Set 先写入临时文件再重命名, 并发读取不会看到写了一半的内容.
+func (s *FileStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	data, err := json.Marshal(fileEntry{Expires: expiresAt(s.now(), ttl), Value: value})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(key))
+}
+
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.Base(key))
+}
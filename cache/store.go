@@ -0,0 +1,24 @@
+// Package cache 提供对话补全结果缓存使用的存储实现.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Store 缓存存储接口, 值为编码后的响应, ttl 为 0 表示不过期.
+type Store interface {
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+func expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+func expired(now, expires time.Time) bool {
+	return !expires.IsZero() && !now.Before(expires)
+}
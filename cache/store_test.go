@@ -0,0 +1,58 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gtkit/go-zhipu/cache"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(2)
+	_ = c.Set(ctx, "a", []byte("1"), 0)
+	_ = c.Set(ctx, "b", []byte("2"), 0)
+	if _, ok, _ := c.Get(ctx, "a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	_ = c.Set(ctx, "c", []byte("3"), 0)
+
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Fatal("expected b to be evicted")
+	}
+	if v, ok, _ := c.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Fatalf("unexpected a: %q %v", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Fatalf("unexpected len %d", c.Len())
+	}
+}
+
+func TestStoresExpireEntries(t *testing.T) {
+	ctx := context.Background()
+	file, err := cache.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range map[string]cache.Store{"lru": cache.NewLRU(10), "file": file} {
+		if err = store.Set(ctx, "short", []byte("x"), time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		if err = store.Set(ctx, "long", []byte("y"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		if _, ok, _ := store.Get(ctx, "short"); ok {
+			t.Errorf("%s: expected short entry to expire", name)
+		}
+		if v, ok, _ := store.Get(ctx, "long"); !ok || string(v) != "y" {
+			t.Errorf("%s: unexpected long entry %q %v", name, v, ok)
+		}
+		if _, ok, _ := store.Get(ctx, "missing"); ok {
+			t.Errorf("%s: unexpected hit", name)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package zhipu
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 )
@@ -11,24 +13,153 @@ const (
 	ChatMessageRoleSystem    = "system"
 	ChatMessageRoleUser      = "user"
 	ChatMessageRoleAssistant = "assistant"
+	ChatMessageRoleTool      = "tool"
 )
 
 const chatCompletionsSuffix = "/invoke"
 const chatStreamCompletionsSuffix = "/sse-invoke"
 
+var ErrContentFieldsMisused = errors.New("can't use both Content and MultiContent properties simultaneously")
+
+type ChatMessagePartType string
+
+const (
+	ChatMessagePartTypeText     ChatMessagePartType = "text"
+	ChatMessagePartTypeImageURL ChatMessagePartType = "image_url"
+)
+
+// ChatMessageImageURL 图片地址, 可以是 http(s) 链接或 data:image/...;base64, 格式的数据.
+type ChatMessageImageURL struct {
+	URL string `json:"url"`
+}
+
+// ChatMessagePart 多模态消息的一个组成部分, GLM-4V 等视觉模型使用.
+type ChatMessagePart struct {
+	Type     ChatMessagePartType  `json:"type"`
+	Text     string               `json:"text,omitempty"`
+	ImageURL *ChatMessageImageURL `json:"image_url,omitempty"`
+}
+
 type ChatCompletionMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// MultiContent 多模态内容, 设置后 content 以数组形式发送, 不能与 Content 同时使用.
+	MultiContent []ChatMessagePart `json:"-"`
+	// ToolCalls assistant 消息中模型发起的工具调用.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID tool 消息对应的工具调用 ID.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+func (m ChatCompletionMessage) MarshalJSON() ([]byte, error) {
+	if m.Content != "" && m.MultiContent != nil {
+		return nil, ErrContentFieldsMisused
+	}
+	if len(m.MultiContent) > 0 {
+		msg := struct {
+			Role         string            `json:"role"`
+			Content      string            `json:"-"`
+			MultiContent []ChatMessagePart `json:"content,omitempty"`
+			ToolCalls    []ToolCall        `json:"tool_calls,omitempty"`
+			ToolCallID   string            `json:"tool_call_id,omitempty"`
+		}(m)
+		return json.Marshal(msg)
+	}
+	msg := struct {
+		Role         string            `json:"role"`
+		Content      string            `json:"content"`
+		MultiContent []ChatMessagePart `json:"-"`
+		ToolCalls    []ToolCall        `json:"tool_calls,omitempty"`
+		ToolCallID   string            `json:"tool_call_id,omitempty"`
+	}(m)
+	return json.Marshal(msg)
+}
+
+func (m *ChatCompletionMessage) UnmarshalJSON(bs []byte) error {
+	msg := struct {
+		Role         string `json:"role"`
+		Content      string `json:"content"`
+		MultiContent []ChatMessagePart
+		ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
+		ToolCallID   string     `json:"tool_call_id,omitempty"`
+	}{}
+	if err := json.Unmarshal(bs, &msg); err == nil {
+		*m = ChatCompletionMessage(msg)
+		return nil
+	}
+
+	multiMsg := struct {
+		Role         string `json:"role"`
+		Content      string
+		MultiContent []ChatMessagePart `json:"content"`
+		ToolCalls    []ToolCall        `json:"tool_calls,omitempty"`
+		ToolCallID   string            `json:"tool_call_id,omitempty"`
+	}{}
+	if err := json.Unmarshal(bs, &multiMsg); err != nil {
+		return err
+	}
+	*m = ChatCompletionMessage(multiMsg)
+	return nil
 }
 
 // ChatCompletionRequest  请求模型参数.
 type ChatCompletionRequest struct {
-	Model       string                  `json:"model"`  // 模型
-	Messages    []ChatCompletionMessage `json:"prompt"` // prompt
-	Temperature float32                 `json:"temperature,omitempty"`
-	TopP        float32                 `json:"top_p,omitempty"`
+	Model    string                  `json:"model"`  // 模型
+	Messages []ChatCompletionMessage `json:"prompt"` // prompt
+	// Temperature 采样温度, 为 nil 时使用模型默认值, 可用 Float32 构造.
+	Temperature *float32 `json:"temperature,omitempty"`
+	// TopP 核采样概率, 为 nil 时使用模型默认值.
+	TopP *float32 `json:"top_p,omitempty"`
+	// MaxTokens 最大输出 tokens, 0 表示使用模型默认值.
+	MaxTokens int `json:"max_tokens,omitempty"`
+	// Stop 遇到其中任一字符串时停止生成.
+	Stop []string `json:"stop,omitempty"`
+	// DoSample 为 false 时关闭采样, Temperature 与 TopP 不再生效.
+	DoSample *bool `json:"do_sample,omitempty"`
+	// Seed 随机种子, 相同种子与参数的请求尽量返回相同结果.
+	Seed *int `json:"seed,omitempty"`
+	// RequestID 调用方传入的请求 ID, 需唯一, 为空时由平台生成.
+	RequestID string `json:"request_id,omitempty"`
+	// UserID 终端用户的唯一 ID, 用于平台侧识别滥用.
+	UserID string `json:"user_id,omitempty"`
+	// ReturnType 返回内容的类型, 见 ReturnTypeJSONString 与 ReturnTypeText.
+	ReturnType string `json:"return_type,omitempty"`
+	// SensitiveWordCheck 敏感词检测控制, 为 nil 时使用平台默认策略.
+	SensitiveWordCheck *SensitiveWordCheck `json:"sensitive_word_check,omitempty"`
 	// 智谱 SSE接口调用时，用于控制每次返回内容方式是增量还是全量，不提供此参数时默认为增量返回 - true 为增量返回 - false 为全量返回
 	Incremental bool `json:"incremental"`
+	// Tools 模型可调用的工具, 例如 web_search 网络检索.
+	Tools []Tool `json:"tools,omitempty"`
+	// ResponseFormat 指定输出格式, 例如 json_object 要求模型输出合法 JSON.
+	ResponseFormat *ChatCompletionResponseFormat `json:"response_format,omitempty"`
+}
+
+const (
+	ReturnTypeJSONString = "json_string"
+	ReturnTypeText       = "text"
+)
+
+// SensitiveWordCheck 敏感词检测设置.
+type SensitiveWordCheck struct {
+	// Type 检测类型, 目前仅支持 "ALL".
+	Type string `json:"type,omitempty"`
+	// Status "ENABLE" 开启, "DISABLE" 关闭检测.
+	Status string `json:"status,omitempty"`
+}
+
+// Float32 返回 v 的指针, 用于设置 Temperature 与 TopP.
+func Float32(v float32) *float32 {
+	return &v
+}
+
+// Bool 返回 v 的指针, 用于设置 DoSample.
+func Bool(v bool) *bool {
+	return &v
+}
+
+// Int 返回 v 的指针, 用于设置 Seed.
+func Int(v int) *int {
+	return &v
 }
 
 // ChatglmCompletionResponse Api文本返回.
@@ -43,10 +174,27 @@ type ChatglmCompletionResponse struct {
 		Usage      struct {
 			TotalTokens int `json:"total_tokens"`
 		} `json:"usage"`
+		WebSearch []WebSearchResult `json:"web_search,omitempty"`
 	} `json:"data"`
 	Success bool `json:"success"`
 }
 
+// zhipuResponse 由 HTTP 状态码为 200 但在响应体中返回错误码的响应实现.
+type zhipuResponse interface {
+	responseError() error
+}
+
+func (r *ChatglmCompletionResponse) responseError() error {
+	if r.Success || r.Code == 0 || r.Code == http.StatusOK {
+		return nil
+	}
+	return &APIError{
+		Code:           r.Code,
+		Message:        r.Msg,
+		HTTPStatusCode: http.StatusOK,
+	}
+}
+
 type ChatCompletionChoice struct {
 	Message ChatCompletionMessage `json:"message"`
 }
@@ -59,24 +207,90 @@ type ChatCompletionResponse struct {
 	Model   string                 `json:"model"`
 	Choices []ChatCompletionChoice `json:"choices"`
 	Usage   Usage                  `json:"usage,omitempty"`
+	// WebSearch 开启 web_search 工具时返回的检索结果, 可用于展示引用来源.
+	WebSearch []WebSearchResult `json:"web_search,omitempty"`
+	// Backend 实际提供该结果的后端名称.
+	Backend string `json:"backend,omitempty"`
+	// Cached 结果来自响应缓存.
+	Cached bool `json:"cached,omitempty"`
 }
 
 // CreateChatCompletion — API call to Create a completion for the chat message.
+// 配置了多个后端时按顺序尝试, 上游暂时不可用时切换到下一个, Backend 字段为实际提供结果的后端.
 func (c *Client) CreateChatCompletion(
 	ctx context.Context,
 	request ChatCompletionRequest,
 ) (response ChatCompletionResponse, err error) {
+	if err = c.validateRequest(request); err != nil {
+		return
+	}
+
+	key, cacheable := c.cacheKey(ctx, request)
+	if cacheable {
+		if cached, ok := c.cachedResponse(ctx, key); ok {
+			return cached, nil
+		}
+	}
+
+	reservation, err := c.reserveBudget(ctx, request)
+	if err != nil {
+		return
+	}
+	defer func() { reservation.settle(response.Usage, err) }()
+
+	for _, backend := range c.backends() {
+		response, err = c.hedgedChatCompletion(ctx, backend, request)
+		if err == nil {
+			response.Backend = backend.Name
+			c.recordUsage(ctx, request.Model, response.Usage)
+			if cacheable {
+				c.storeResponse(ctx, key, response)
+			}
+			return
+		}
+		if !c.reportBackendError(ctx, backend, err) {
+			return
+		}
+	}
+	return
+}
+
+func (c *Client) createChatCompletion(
+	ctx context.Context,
+	backend Backend,
+	request ChatCompletionRequest,
+) (response ChatCompletionResponse, err error) {
+	request.Model = backend.model(request.Model)
+	endpoint := chatCompletionsSuffix
+	if backend.Kind != BackendZhipuV3 {
+		endpoint = openAIChatCompletionsSuffix
+	}
+	probe, err := c.allowCircuit(backend, request.Model, endpoint)
+	if err != nil {
+		return
+	}
+	defer func() { probe.done(err) }()
+
+	if backend.Kind != BackendZhipuV3 {
+		return c.createOpenAIChatCompletion(ctx, backend, request)
+	}
+
 	urlSuffix := chatCompletionsSuffix
 
-	req, err := c.newRequest(ctx, http.MethodPost, c.fullURL(urlSuffix, request.Model), withBody(request))
+	req, err := c.newRequest(ctx, http.MethodPost, backend.baseURL()+request.Model+urlSuffix, withBody(request))
 	if err != nil {
 		return
 	}
+	c.setBackendAuth(req, backend)
+
 	var glm ChatglmCompletionResponse
 
 	if err = c.sendRequest(req, &glm); err != nil {
 		return
 	}
+	if len(glm.Data.Choices) == 0 {
+		return response, ErrNoChoices
+	}
 
 	return ChatCompletionResponse{
 		ID:      glm.Data.TaskID,
@@ -91,5 +305,6 @@ func (c *Client) CreateChatCompletion(
 		Usage: Usage{
 			TotalTokens: glm.Data.Usage.TotalTokens,
 		},
+		WebSearch: glm.Data.WebSearch,
 	}, nil
 }
@@ -3,6 +3,7 @@ package zhipu
 import (
 	"context"
 	"net/http"
+	"time"
 )
 
 type ChatCompletionStreamChoiceDelta struct {
@@ -19,32 +20,151 @@ type GlmChatCompletionStreamResponse struct {
 	Event   string                       `json:"event"`
 	Choices []ChatCompletionStreamChoice `json:"choices"`
 	Meta    GlmMeta                      `json:"meta"`
+	// WebSearch 开启 web_search 工具时随 meta 返回的检索结果.
+	WebSearch []WebSearchResult `json:"web_search,omitempty"`
 }
 
 type GlmChatCompletionStream struct {
 	*streamReader[GlmChatCompletionStreamResponse]
+	// Backend 实际提供该流的后端名称.
+	Backend string
+	// Cached 流是由缓存的响应回放的.
+	Cached bool
+
+	// observers 每次 Recv 返回前回调, 用于用量统计等.
+	observers []func(response GlmChatCompletionStreamResponse, err error)
+	// onClose Close 时回调.
+	onClose []func()
+	// transcript 设置了 WithTranscript 时的记录器.
+	transcript *transcriptRecorder
+}
+
+func (s *GlmChatCompletionStream) Recv() (response GlmChatCompletionStreamResponse, err error) {
+	response, err = s.streamReader.Recv()
+	s.notify(response, err)
+	return
+}
+
+func (s *GlmChatCompletionStream) Close() {
+	s.streamReader.Close()
+	for _, fn := range s.onClose {
+		fn()
+	}
+}
+
+func (s *GlmChatCompletionStream) notify(response GlmChatCompletionStreamResponse, err error) {
+	for _, observe := range s.observers {
+		observe(response, err)
+	}
+}
+
+func (s *GlmChatCompletionStream) observe(fn func(response GlmChatCompletionStreamResponse, err error)) {
+	s.observers = append(s.observers, fn)
 }
+
 type GlmMeta struct {
 	TaskStatus string `json:"task_status"`
 	Usage      struct {
 		TotalTokens int `json:"total_tokens"`
 	} `json:"usage"`
-	TaskID    string `json:"task_id"`
-	RequestID string `json:"request_id"`
+	TaskID    string            `json:"task_id"`
+	RequestID string            `json:"request_id"`
+	WebSearch []WebSearchResult `json:"web_search,omitempty"`
 }
 
+// CreateChatCompletionStream 创建流式对话补全, 建立连接失败且上游暂时不可用时切换到下一个后端.
+// 启用缓存时, 命中的请求以缓存内容回放, 不访问上游; 流式调用的结果不写入缓存.
 func (c *Client) CreateChatCompletionStream(
 	ctx context.Context,
 	request ChatCompletionRequest,
+) (stream *GlmChatCompletionStream, err error) {
+	if err = c.validateRequest(request); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	if key, ok := c.cacheKey(ctx, request); ok {
+		if cached, hit := c.cachedResponse(ctx, key); hit {
+			if stream, err = newCachedStream(cached); err != nil {
+				return nil, err
+			}
+			if err = recordTranscript(ctx, stream, start); err != nil {
+				stream.Close()
+				return nil, err
+			}
+			return stream, nil
+		}
+	}
+
+	reservation, err := c.reserveBudget(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, backend := range c.backends() {
+		stream, err = c.hedgedChatCompletionStream(ctx, backend, request)
+		if err == nil {
+			stream.Backend = backend.Name
+			if err = recordTranscript(ctx, stream, start); err != nil {
+				stream.Close()
+				stream = nil
+				break
+			}
+			c.observeStreamUsage(ctx, stream, request.Model)
+			reservation.settleStream(stream)
+			return
+		}
+		if !c.reportBackendError(ctx, backend, err) {
+			break
+		}
+	}
+	reservation.settle(Usage{}, err)
+	return
+}
+
+func (c *Client) createChatCompletionStream(
+	ctx context.Context,
+	backend Backend,
+	request ChatCompletionRequest,
+) (*GlmChatCompletionStream, error) {
+	request.Model = backend.model(request.Model)
+	endpoint := chatStreamCompletionsSuffix
+	if backend.Kind != BackendZhipuV3 {
+		endpoint = openAIChatCompletionsSuffix
+	}
+	probe, err := c.allowCircuit(backend, request.Model, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := c.openChatCompletionStream(ctx, backend, request)
+	if err != nil {
+		probe.done(err)
+		return nil, err
+	}
+	observeStreamCircuit(stream, probe)
+	return stream, nil
+}
+
+// openChatCompletionStream 按后端类型建立流, request.Model 已映射为后端的模型名.
+func (c *Client) openChatCompletionStream(
+	ctx context.Context,
+	backend Backend,
+	request ChatCompletionRequest,
 ) (*GlmChatCompletionStream, error) {
+	if backend.Kind != BackendZhipuV3 {
+		return c.createOpenAIChatCompletionStream(ctx, backend, request)
+	}
+
 	urlSuffix := chatStreamCompletionsSuffix
 
-	req, err := c.newRequest(ctx, http.MethodPost, c.fullURL(urlSuffix, request.Model), withBody(request))
+	req, err := c.newRequest(ctx, http.MethodPost, backend.baseURL()+request.Model+urlSuffix, withBody(request))
 	if err != nil {
 		return nil, err
 	}
+	c.setBackendAuth(req, backend)
 
-	resp, err := sendRequestStream[GlmChatCompletionStreamResponse](c, req)
+	resp, err := sendRequestStream[GlmChatCompletionStreamResponse](c, req, &glmStreamDecoder{})
 	if err != nil {
 		return nil, err
 	}
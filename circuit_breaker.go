@@ -0,0 +1,316 @@
+package zhipu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCircuitFailureRate = 0.5
+	defaultCircuitMinRequests = 10
+	defaultCircuitWindow      = 30 * time.Second
+	defaultCircuitOpenTimeout = 30 * time.Second
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState 熔断器状态.
+type CircuitState int
+
+const (
+	// CircuitClosed 正常放行请求并统计失败率.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen 直接拒绝请求, 等待 OpenTimeout 后进入半开.
+	CircuitOpen
+	// CircuitHalfOpen 放行少量探测请求, 都成功后关闭, 任一失败重新打开.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitKey 熔断的粒度: 后端、模型与接口后缀, 例如 {"zhipu", "glm-4", "/sse-invoke"}.
+type CircuitKey struct {
+	Backend  string
+	Model    string
+	Endpoint string
+}
+
+func (k CircuitKey) String() string {
+	return k.Backend + ":" + k.Model + k.Endpoint
+}
+
+// CircuitOpenError 熔断器打开时请求被拒绝, errors.Is(err, ErrCircuitOpen) 为 true.
+// 配置了多个后端时会切换到下一个后端.
+type CircuitOpenError struct {
+	Key CircuitKey
+	// RetryAfter 距离进入半开状态的时间, 半开状态下探测请求已满时为 0.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: %s, retry after %s", ErrCircuitOpen, e.Key, e.RetryAfter)
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitBreaker 按 CircuitKey 统计上游失败率, 失败率过高时快速失败, 避免请求堆积在超时上.
+// 网络错误、超时、429 与 5xx 计为失败, 调用方取消的请求不计入.
+type CircuitBreaker struct {
+	failureRate    float64
+	minRequests    int
+	window         time.Duration
+	openTimeout    time.Duration
+	halfOpenProbes int
+	onStateChange  func(key CircuitKey, from, to CircuitState)
+	now            func() time.Time
+
+	mu       sync.Mutex
+	circuits map[CircuitKey]*circuit
+}
+
+type CircuitBreakerOption func(*CircuitBreaker)
+
+// WithCircuitFailureRate 窗口内至少 minRequests 个请求且失败率达到 rate 时打开, 默认 50% 与 10 个请求.
+func WithCircuitFailureRate(rate float64, minRequests int) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.failureRate = rate
+		b.minRequests = minRequests
+	}
+}
+
+// WithCircuitWindow 统计失败率的时间窗口, 默认 30 秒.
+func WithCircuitWindow(window time.Duration) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.window = window
+	}
+}
+
+// WithCircuitOpenTimeout 打开后进入半开状态前的等待时间, 默认 30 秒.
+func WithCircuitOpenTimeout(timeout time.Duration) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.openTimeout = timeout
+	}
+}
+
+// WithCircuitHalfOpenProbes 半开状态下放行的探测请求数, 都成功后关闭, 默认 1.
+func WithCircuitHalfOpenProbes(probes int) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.halfOpenProbes = probes
+	}
+}
+
+// WithCircuitStateChange 状态变化时回调, 可用于告警. 回调在锁外同步执行.
+func WithCircuitStateChange(fn func(key CircuitKey, from, to CircuitState)) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.onStateChange = fn
+	}
+}
+
+// NewCircuitBreaker 创建熔断器, 可以被多个客户端共用.
+func NewCircuitBreaker(opts ...CircuitBreakerOption) *CircuitBreaker {
+	b := &CircuitBreaker{
+		failureRate:    defaultCircuitFailureRate,
+		minRequests:    defaultCircuitMinRequests,
+		window:         defaultCircuitWindow,
+		openTimeout:    defaultCircuitOpenTimeout,
+		halfOpenProbes: 1,
+		now:            time.Now,
+		circuits:       make(map[CircuitKey]*circuit),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.halfOpenProbes < 1 {
+		b.halfOpenProbes = 1
+	}
+	return b
+}
+
+// State 返回 key 当前的状态, 打开超时后尚未有请求时仍为 CircuitOpen.
+func (b *CircuitBreaker) State(key CircuitKey) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[key]; ok {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+type circuit struct {
+	state CircuitState
+	// generation 每次状态变化递增, 丢弃状态变化前发出的请求的结果.
+	generation int
+
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	openedAt  time.Time
+	probes    int
+	successes int
+}
+
+type circuitTransition struct {
+	key      CircuitKey
+	from, to CircuitState
+}
+
+func (c *circuit) transition(key CircuitKey, to CircuitState, now time.Time) circuitTransition {
+	t := circuitTransition{key: key, from: c.state, to: to}
+	c.state = to
+	c.generation++
+	c.windowStart, c.requests, c.failures = now, 0, 0
+	c.probes, c.successes = 0, 0
+	if to == CircuitOpen {
+		c.openedAt = now
+	}
+	return t
+}
+
+// circuitProbe 一个被放行的请求, 结束时调用 done 记录结果.
+type circuitProbe struct {
+	breaker    *CircuitBreaker
+	key        CircuitKey
+	generation int
+	once       sync.Once
+}
+
+// allow 熔断器打开时返回 CircuitOpenError, breaker 为 nil 时总是放行.
+func (b *CircuitBreaker) allow(key CircuitKey) (*circuitProbe, error) {
+	if b == nil {
+		return nil, nil
+	}
+
+	var transitions []circuitTransition
+	defer func() { b.notify(transitions) }()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{windowStart: now}
+		b.circuits[key] = c
+	}
+
+	switch c.state {
+	case CircuitClosed:
+		if now.Sub(c.windowStart) >= b.window {
+			c.windowStart, c.requests, c.failures = now, 0, 0
+		}
+	case CircuitOpen:
+		if wait := c.openedAt.Add(b.openTimeout).Sub(now); wait > 0 {
+			return nil, &CircuitOpenError{Key: key, RetryAfter: wait}
+		}
+		transitions = append(transitions, c.transition(key, CircuitHalfOpen, now))
+		fallthrough
+	case CircuitHalfOpen:
+		if c.probes >= b.halfOpenProbes {
+			return nil, &CircuitOpenError{Key: key}
+		}
+		c.probes++
+	}
+	return &circuitProbe{breaker: b, key: key, generation: c.generation}, nil
+}
+
+// done 记录请求的结果, 只有第一次调用生效; probe 为 nil 时忽略.
+func (p *circuitProbe) done(err error) {
+	if p == nil {
+		return
+	}
+	p.once.Do(func() { p.breaker.record(p, err) })
+}
+
+func (b *CircuitBreaker) record(p *circuitProbe, err error) {
+	var transitions []circuitTransition
+	defer func() { b.notify(transitions) }()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[p.key]
+	if c.generation != p.generation {
+		return
+	}
+	now := b.now()
+	failed := isCircuitFailure(err)
+	ignored := !failed && err != nil && errors.Is(err, context.Canceled)
+
+	switch c.state {
+	case CircuitClosed:
+		if ignored {
+			return
+		}
+		c.requests++
+		if failed {
+			c.failures++
+		}
+		if c.requests >= b.minRequests && float64(c.failures)/float64(c.requests) >= b.failureRate {
+			transitions = append(transitions, c.transition(p.key, CircuitOpen, now))
+		}
+	case CircuitHalfOpen:
+		c.probes--
+		switch {
+		case failed:
+			transitions = append(transitions, c.transition(p.key, CircuitOpen, now))
+		case !ignored:
+			c.successes++
+			if c.successes >= b.halfOpenProbes {
+				transitions = append(transitions, c.transition(p.key, CircuitClosed, now))
+			}
+		}
+	}
+}
+
+func (b *CircuitBreaker) notify(transitions []circuitTransition) {
+	if b.onStateChange == nil {
+		return
+	}
+	for _, t := range transitions {
+		b.onStateChange(t.key, t.from, t.to)
+	}
+}
+
+// isCircuitFailure 判断错误是否说明上游不可用. 密钥不足与熔断本身不计入.
+func isCircuitFailure(err error) bool {
+	if err == nil || errors.Is(err, ErrNoAvailableKey) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	return isRetryableError(err) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrFirstTokenTimeout)
+}
+
+// allowCircuit 配置了 CircuitBreaker 时检查 backend 上 model 与 endpoint 对应的熔断器.
+func (c *Client) allowCircuit(backend Backend, model, endpoint string) (*circuitProbe, error) {
+	return c.config.CircuitBreaker.allow(CircuitKey{Backend: backend.Name, Model: model, Endpoint: endpoint})
+}
+
+// observeStreamCircuit 以流的第一个事件作为请求的结果, 未读取就关闭的流不计入.
+func observeStreamCircuit(stream *GlmChatCompletionStream, probe *circuitProbe) {
+	if probe == nil {
+		return
+	}
+	stream.observe(func(_ GlmChatCompletionStreamResponse, err error) {
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		probe.done(err)
+	})
+	stream.onClose = append(stream.onClose, func() { probe.done(context.Canceled) })
+}
@@ -1,19 +1,22 @@
 package zhipu
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 
+	"github.com/gtkit/go-zhipu/sse"
 	"github.com/gtkit/go-zhipu/utils"
 )
 
 type ChatCompletion[T ChatCompletionRequest] interface {
 	CreateChatCompletionStream(ctx context.Context, request T) (stream *GlmChatCompletionStream, err error)
 	CreateChatCompletion(ctx context.Context, request T) (response ChatCompletionResponse, err error)
+	CreateEmbeddings(ctx context.Context, request EmbeddingRequest) (response EmbeddingResponse, err error)
 	newRequest(ctx context.Context, method, url string, setters ...requestOption) (*http.Request, error)
 	sendRequest(req *http.Request, v any) error
 	setCommonHeaders(req *http.Request)
@@ -64,6 +67,7 @@ func (c *Client) newRequest(ctx context.Context, method, url string, setters ...
 	return req, nil
 }
 
+// sendRequest 发送请求并解析响应, 密钥池中的密钥返回鉴权或配额错误时换下一个密钥重新发送.
 func (c *Client) sendRequest(req *http.Request, v any) error {
 	req.Header.Set("Accept", "application/json; charset=utf-8")
 
@@ -72,6 +76,25 @@ func (c *Client) sendRequest(req *http.Request, v any) error {
 		req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	}
 
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		key, err := c.authorize(req)
+		if err != nil {
+			return keyPoolError(err, lastErr)
+		}
+		err = c.send(req, v)
+		c.config.KeyPool.release(key, err)
+		if err == nil {
+			return nil
+		}
+		if rotateErr := c.rotateKey(req, key, err, attempt); rotateErr != nil {
+			return rotateErr
+		}
+		lastErr = err
+	}
+}
+
+func (c *Client) send(req *http.Request, v any) error {
 	res, err := c.config.HTTPClient.Do(req)
 	if err != nil {
 		return err
@@ -83,7 +106,26 @@ func (c *Client) sendRequest(req *http.Request, v any) error {
 		return c.handleErrorResp(res)
 	}
 
-	return decodeResponse(res.Body, v)
+	if err = decodeResponse(res.Body, v); err != nil {
+		return err
+	}
+	if r, ok := v.(zhipuResponse); ok {
+		return r.responseError()
+	}
+	return nil
+}
+
+// authorize 配置了密钥池时使用池中的密钥重新签名请求, 使用后端自身凭证的请求保持不变.
+func (c *Client) authorize(req *http.Request) (*pooledKey, error) {
+	if c.config.KeyPool == nil || hasExplicitAuth(req) {
+		return nil, nil
+	}
+	key, token, err := c.config.KeyPool.acquire()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authScheme(req)+token)
+	return key, nil
 }
 
 func (c *Client) setCommonHeaders(req *http.Request) {
@@ -122,26 +164,145 @@ func (c *Client) handleErrorResp(resp *http.Response) error {
 	return errRes.Error
 }
 
-func sendRequestStream[T streamable](client *Client, req *http.Request) (*streamReader[T], error) {
+func sendRequestStream[T any](client *Client, req *http.Request, decoder StreamDecoder[T]) (*streamReader[T], error) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "text/event-stream")
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Connection", "keep-alive")
 
-	resp, err := client.config.HTTPClient.Do(req) //nolint:bodyclose // body is closed in stream.Close()
+	deadline := newStreamDeadline(client.config.StreamTimeouts)
+	req = deadline.bindRequest(req)
+	resp, err := client.openPooledStream(req)
+	if timeoutErr := deadline.receivedHeaders(); timeoutErr != nil {
+		if err == nil {
+			resp.Body.Close()
+		}
+		err = timeoutErr
+	}
 	if err != nil {
+		deadline.stop()
 		return new(streamReader[T]), err
 	}
+
+	stream := newStreamReader(resp, decoder)
+	stream.deadline = deadline
+	return stream, nil
+}
+
+// openPooledStream 发送流式请求, 密钥池中的密钥返回鉴权或配额错误时换下一个密钥重新发送.
+func (c *Client) openPooledStream(req *http.Request) (*http.Response, error) {
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		key, err := c.authorize(req)
+		if err != nil {
+			return nil, keyPoolError(err, lastErr)
+		}
+		resp, err := c.openStream(req)
+		c.config.KeyPool.release(key, err)
+		if err == nil {
+			return resp, nil
+		}
+		if rotateErr := c.rotateKey(req, key, err, attempt); rotateErr != nil {
+			return nil, rotateErr
+		}
+		lastErr = err
+	}
+}
+
+// openStream 发送流式请求, 上游拒绝请求时读取错误响应并关闭连接.
+func (c *Client) openStream(req *http.Request) (*http.Response, error) {
+	resp, err := c.config.HTTPClient.Do(req) //nolint:bodyclose // body is closed in stream.Close()
+	if err != nil {
+		return nil, err
+	}
 	if isFailureStatusCode(resp) {
-		return new(streamReader[T]), client.handleErrorResp(resp)
+		defer resp.Body.Close()
+		return nil, c.handleErrorResp(resp)
+	}
+	// 请求被拒绝时流式接口也可能返回 200 与 JSON 错误体.
+	if isJSONResponse(resp) {
+		return nil, handleStreamJSONResp(resp)
 	}
+	return resp, nil
+}
+
+// rotateKey 密钥因鉴权或配额错误被隔离后重置请求体, 返回 nil 表示可以用下一个密钥重新发送.
+// 不需要换密钥时原样返回 err, 池中的密钥都已尝试过时返回 ErrNoAvailableKey.
+func (c *Client) rotateKey(req *http.Request, key *pooledKey, err error, attempt int) error {
+	if key == nil || (!isAuthError(err) && !isThrottleError(err)) {
+		return err
+	}
+	if attempt+1 >= c.config.KeyPool.size() {
+		return fmt.Errorf("%w: %w", ErrNoAvailableKey, err)
+	}
+	if req.GetBody != nil {
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return err
+		}
+		req.Body = body
+	}
+	return nil
+}
+
+// keyPoolError 换密钥重试时没有可用密钥, 附上最后一次请求的错误.
+func keyPoolError(err, lastErr error) error {
+	if lastErr == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", err, lastErr)
+}
 
-	return &streamReader[T]{
-		reader:         bufio.NewReader(resp.Body),
+func newStreamReader[T any](resp *http.Response, decoder StreamDecoder[T]) *streamReader[T] {
+	stream := &streamReader[T]{
+		events:         sse.NewDecoder(resp.Body),
+		decoder:        decoder,
 		response:       resp,
 		errAccumulator: utils.NewErrorAccumulator(),
-		unmarshaler:    &utils.JSONUnmarshaler{},
-	}, nil
+	}
+	stream.events.Unknown = stream.captureLine
+	return stream
+}
+
+func isJSONResponse(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// handleStreamJSONResp 读取流式接口返回的 JSON 响应体并转换为错误.
+func handleStreamJSONResp(resp *http.Response) error {
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err != nil {
+		return &RequestError{HTTPStatusCode: resp.StatusCode, Err: err}
+	}
+	if apiErr := decodeErrorBody(resp.StatusCode, data); apiErr != nil {
+		return apiErr
+	}
+	return &RequestError{
+		HTTPStatusCode: resp.StatusCode,
+		Err:            fmt.Errorf("unexpected JSON response from stream endpoint: %s", data),
+	}
+}
+
+// decodeErrorBody 解析 {"error":{...}} 或智谱 {"code":...,"msg":...} 格式的错误响应体, 无法识别时返回 nil.
+func decodeErrorBody(statusCode int, data []byte) *APIError {
+	var errRes ErrorResponse
+	if err := json.Unmarshal(data, &errRes); err == nil && errRes.Error != nil {
+		errRes.Error.HTTPStatusCode = statusCode
+		return errRes.Error
+	}
+
+	var glm ChatglmCompletionResponse
+	if err := json.Unmarshal(data, &glm); err != nil {
+		return nil
+	}
+	var apiErr *APIError
+	if errors.As(glm.responseError(), &apiErr) {
+		apiErr.HTTPStatusCode = statusCode
+		return apiErr
+	}
+	return nil
 }
 
 func withBody(body any) requestOption {
@@ -0,0 +1,64 @@
+// Command zhipu-proxy 提供兼容 OpenAI 的 HTTP 接口, 将请求转发到智谱.
+//
+//	ZHIPU_API_KEY=id.secret zhipu-proxy -addr :8080
+//
+// 之后将 OpenAI 客户端的 base URL 设置为 http://localhost:8080/v1 即可.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gtkit/go-zhipu"
+	"github.com/gtkit/go-zhipu/proxy"
+)
+
+const tokenTTL = 12 * time.Hour
+
+func main() {
+	var (
+		addr      = flag.String("addr", ":8080", "listen address")
+		key       = flag.String("key", os.Getenv("ZHIPU_API_KEY"), "Zhipu API key (id.secret), defaults to $ZHIPU_API_KEY")
+		baseURL   = flag.String("base-url", os.Getenv("ZHIPU_BASE_URL"), "Zhipu API base URL")
+		proxyKeys = flag.String("proxy-keys", os.Getenv("ZHIPU_PROXY_KEYS"), "comma separated keys clients must send as Bearer tokens")
+		aliases   = flag.String("aliases", "", "comma separated model aliases, e.g. gpt-4=glm-4,gpt-3.5-turbo=chatglm_turbo")
+	)
+	flag.Parse()
+
+	pool, err := zhipu.NewKeyPool(strings.Split(*key, ","), zhipu.WithKeyTokenTTL(tokenTTL))
+	if err != nil {
+		log.Fatalf("zhipu-proxy: %v", err)
+	}
+	config := zhipu.DefaultConfigWithKeyPool(pool)
+	if *baseURL != "" {
+		config.BaseURL = strings.TrimSuffix(*baseURL, "/") + "/"
+	}
+
+	var opts []proxy.Option
+	if *proxyKeys != "" {
+		opts = append(opts, proxy.WithAPIKeys(strings.Split(*proxyKeys, ",")...))
+	}
+	if *aliases != "" {
+		models := make(map[string]string)
+		for _, pair := range strings.Split(*aliases, ",") {
+			from, to, ok := strings.Cut(pair, "=")
+			if !ok {
+				log.Fatalf("zhipu-proxy: invalid alias %q", pair)
+			}
+			models[from] = to
+		}
+		opts = append(opts, proxy.WithModelAliases(models))
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           proxy.NewHandler(zhipu.NewClientWithConfig(config), opts...),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("zhipu-proxy listening on %s", *addr)
+	log.Fatal(server.ListenAndServe())
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+const batchUsage = `usage: zhipu batch [flags] [input.jsonl]
+
+Each input line is a ChatCompletionRequest with an optional "id", e.g.
+  {"id":"q1","model":"chatglm_turbo","prompt":[{"role":"user","content":"hi"}]}
+Lines without an id use their line number. Results are written in input order.
+Malformed lines are written as errors under their line number and retried by -resume.
+Reads stdin when no input file is given.
+`
+
+// batchInput 输入的一行, 没有 id 时使用行号.
+type batchInput struct {
+	ID string `json:"id"`
+	zhipu.ChatCompletionRequest
+}
+
+// batchResult 输出的一行.
+type batchResult struct {
+	ID       string                        `json:"id"`
+	Line     int                           `json:"line"`
+	Response *zhipu.ChatCompletionResponse `json:"response,omitempty"`
+	Error    string                        `json:"error,omitempty"`
+}
+
+type batchJob struct {
+	index int
+	line  int
+	input batchInput
+	// invalid 该行无法解析, 不发送请求, 直接写出错误.
+	invalid error
+}
+
+type batchOutcome struct {
+	index    int
+	result   batchResult
+	canceled bool
+}
+
+func runBatch(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("zhipu batch", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, batchUsage)
+		fs.PrintDefaults()
+	}
+
+	var (
+		cf          clientFlags
+		outPath     string
+		concurrency int
+		rps         float64
+		resume      bool
+		quiet       bool
+	)
+	cf.register(fs)
+	fs.StringVar(&outPath, "out", "", "output JSONL file, defaults to stdout")
+	fs.IntVar(&concurrency, "concurrency", 4, "number of concurrent requests")
+	fs.Float64Var(&rps, "rps", 0, "maximum requests per second, 0 means unlimited")
+	fs.BoolVar(&resume, "resume", false, "append to -out and skip ids that already succeeded in it")
+	fs.BoolVar(&quiet, "quiet", false, "do not print progress")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if concurrency < 1 || rps < 0 || fs.NArg() > 1 || (resume && outPath == "") {
+		fs.Usage()
+		return 2
+	}
+
+	if err := batch(fs.Arg(0), outPath, cf, concurrency, rps, resume, quiet, stdin, stdout, stderr); err != nil {
+		fmt.Fprintln(stderr, "zhipu batch:", err)
+		return 1
+	}
+	return 0
+}
+
+func batch(
+	inPath, outPath string,
+	cf clientFlags,
+	concurrency int,
+	rps float64,
+	resume, quiet bool,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) error {
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+
+	in := stdin
+	if inPath != "" && inPath != "-" {
+		f, openErr := os.Open(inPath)
+		if openErr != nil {
+			return openErr
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var done map[string]bool
+	if resume {
+		if done, err = completedIDs(outPath); err != nil {
+			return err
+		}
+	}
+
+	jobs, skipped, err := readBatchInput(in, cf.model, done)
+	if err != nil {
+		return err
+	}
+
+	out := stdout
+	if outPath != "" {
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if resume {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		f, openErr := os.OpenFile(outPath, flags, 0o644)
+		if openErr != nil {
+			return openErr
+		}
+		defer f.Close()
+		out = f
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	progress := func(completed, failed int) {
+		if !quiet {
+			fmt.Fprintf(stderr, "\r%d/%d done, %d failed, %d skipped", completed, len(jobs), failed, skipped)
+		}
+	}
+	written, err := executeBatch(ctx, client, jobs, concurrency, rps, out, progress)
+	if !quiet {
+		fmt.Fprintln(stderr)
+	}
+	if err != nil {
+		return err
+	}
+	if written < len(jobs) {
+		return fmt.Errorf("interrupted after %d of %d requests, rerun with -resume to continue", written, len(jobs))
+	}
+	return nil
+}
+
+// completedIDs 读取已有输出中成功的 id, 失败的请求留给 -resume 重试. 文件不存在时返回空集合.
+func completedIDs(path string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := newLineScanner(f)
+	for scanner.Scan() {
+		var r batchResult
+		if json.Unmarshal(scanner.Bytes(), &r) == nil && r.ID != "" && r.Error == "" {
+			done[r.ID] = true
+		}
+	}
+	return done, scanner.Err()
+}
+
+// readBatchInput 读取输入并跳过 done 中的 id, 重复的 id 会使 -resume 无法区分请求, 直接报错.
+// 无法解析的行以行号作为 id 生成只写出错误的任务, 不影响其他行.
+func readBatchInput(r io.Reader, model string, done map[string]bool) (jobs []batchJob, skipped int, err error) {
+	seen := make(map[string]int)
+	scanner := newLineScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var input batchInput
+		invalid := json.Unmarshal(data, &input)
+		if invalid != nil {
+			input = batchInput{}
+		}
+		if input.ID == "" {
+			input.ID = strconv.Itoa(line)
+		}
+		if input.Model == "" {
+			input.Model = model
+		}
+		if first, ok := seen[input.ID]; ok {
+			return nil, 0, fmt.Errorf("line %d: duplicate id %q, first used on line %d", line, input.ID, first)
+		}
+		seen[input.ID] = line
+		if done[input.ID] {
+			skipped++
+			continue
+		}
+		jobs = append(jobs, batchJob{index: len(jobs), line: line, input: input, invalid: invalid})
+	}
+	return jobs, skipped, scanner.Err()
+}
+
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return scanner
+}
+
+// executeBatch 并发执行请求并按输入顺序写出结果, 返回写出的条数.
+// 中断时只写出已完成且之前没有空缺的结果, 被取消的请求留给 -resume 重新执行.
+func executeBatch(
+	ctx context.Context,
+	client zhipu.ChatCompletion[zhipu.ChatCompletionRequest],
+	jobs []batchJob,
+	concurrency int,
+	rps float64,
+	out io.Writer,
+	progress func(completed, failed int),
+) (int, error) {
+	queue := make(chan batchJob)
+	outcomes := make(chan batchOutcome)
+
+	var limiter <-chan time.Time
+	if rps > 0 {
+		// rps 超过 1e9 时间隔不足 1ns, NewTicker 不接受 0.
+		period := time.Duration(float64(time.Second) / rps)
+		if period < 1 {
+			period = 1
+		}
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				outcomes <- runBatchJob(ctx, client, job, limiter)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(queue)
+		for _, job := range jobs {
+			select {
+			case queue <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+
+	var (
+		pending   = make(map[int]batchOutcome)
+		next      int
+		completed int
+		failed    int
+		blocked   bool
+		writeErr  error
+	)
+	for outcome := range outcomes {
+		completed++
+		if outcome.result.Error != "" && !outcome.canceled {
+			failed++
+		}
+		progress(completed, failed)
+
+		pending[outcome.index] = outcome
+		for !blocked && writeErr == nil {
+			o, ok := pending[next]
+			if !ok {
+				break
+			}
+			if o.canceled {
+				blocked = true
+				break
+			}
+			delete(pending, next)
+			writeErr = enc.Encode(o.result)
+			next++
+		}
+	}
+	return next, writeErr
+}
+
+func runBatchJob(
+	ctx context.Context,
+	client zhipu.ChatCompletion[zhipu.ChatCompletionRequest],
+	job batchJob,
+	limiter <-chan time.Time,
+) batchOutcome {
+	outcome := batchOutcome{index: job.index, result: batchResult{ID: job.input.ID, Line: job.line}}
+	if job.invalid != nil {
+		outcome.result.Error = "invalid input: " + job.invalid.Error()
+		return outcome
+	}
+
+	if limiter != nil {
+		select {
+		case <-limiter:
+		case <-ctx.Done():
+			outcome.canceled = true
+			return outcome
+		}
+	}
+
+	resp, err := client.CreateChatCompletion(ctx, job.input.ChatCompletionRequest)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		outcome.canceled = true
+	case err != nil:
+		outcome.result.Error = err.Error()
+	default:
+		outcome.result.Response = &resp
+	}
+	return outcome
+}
@@ -0,0 +1,178 @@
+package main //nolint:testpackage // testing unexported run entrypoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatchPreservesOrderAndResumes(t *testing.T) {
+	server := newEchoServer(t)
+	defer server.Close()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.jsonl")
+	out := filepath.Join(dir, "out.jsonl")
+	lines := []string{
+		`{"id":"a","prompt":[{"role":"user","content":"first"}]}`,
+		`{"prompt":[{"role":"user","content":"second"}]}`,
+		`{"id":"c","model":"glm-4","prompt":[{"role":"user","content":"third"}]}`,
+	}
+	if err := os.WriteFile(in, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"batch", "-key", "id.secret", "-base-url", server.URL, "-concurrency", "3", "-quiet", "-out", out, in}
+	var stdout, stderr bytes.Buffer
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+
+	results := readResults(t, out)
+	if len(results) != 3 || results[0].ID != "a" || results[1].ID != "2" || results[2].ID != "c" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if got := results[2].Response.Choices[0].Message.Content; got != "echo: third" {
+		t.Fatalf("unexpected content %q", got)
+	}
+
+	lines = append(lines, `{"id":"d","prompt":[{"role":"user","content":"fourth"}]}`)
+	if err := os.WriteFile(in, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	args = append(args[:len(args)-1], "-resume", in)
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+
+	results = readResults(t, out)
+	if len(results) != 4 || results[3].ID != "d" || results[3].Line != 4 {
+		t.Fatalf("unexpected resumed results: %+v", results)
+	}
+}
+
+func TestBatchResumeRetriesFailures(t *testing.T) {
+	server := newEchoServer(t)
+	defer server.Close()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.jsonl")
+	out := filepath.Join(dir, "out.jsonl")
+	input := `{"id":"a","prompt":[{"role":"user","content":"first"}]}` + "\n" +
+		`{"id":"b","prompt":[{"role":"user","content":"second"}]}`
+	previous := `{"id":"a","line":1,"error":"upstream unavailable"}` + "\n" +
+		`{"id":"b","line":2,"response":{"id":"","created":0,"model":"","choices":[],"usage":{}}}` + "\n"
+	if err := os.WriteFile(in, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(out, []byte(previous), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"batch", "-key", "id.secret", "-base-url", server.URL, "-quiet", "-out", out, "-resume", in}
+	var stdout, stderr bytes.Buffer
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+
+	results := readResults(t, out)
+	if len(results) != 3 || results[2].ID != "a" || results[2].Error != "" || results[2].Response == nil {
+		t.Fatalf("failed request was not retried: %+v", results)
+	}
+}
+
+func TestBatchRejectsDuplicateIDs(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.jsonl")
+	input := `{"id":"a","prompt":[{"role":"user","content":"first"}]}` + "\n" +
+		`{"id":"a","prompt":[{"role":"user","content":"second"}]}`
+	if err := os.WriteFile(in, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"batch", "-key", "id.secret", "-quiet", in}, nil, &stdout, &stderr); code != 1 ||
+		!strings.Contains(stderr.String(), `duplicate id "a"`) {
+		t.Fatalf("expected duplicate id error, got exit code %d: %s", code, stderr.String())
+	}
+}
+
+func TestBatchReportsMalformedLines(t *testing.T) {
+	server := newEchoServer(t)
+	defer server.Close()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.jsonl")
+	out := filepath.Join(dir, "out.jsonl")
+	input := `{"id":"a","prompt":[{"role":"user","content":"first"}]}` + "\n" +
+		`{"id":"b","prompt":[` + "\n" +
+		`{"id":"c","prompt":[{"role":"user","content":"third"}]}`
+	if err := os.WriteFile(in, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"batch", "-key", "id.secret", "-base-url", server.URL, "-quiet", "-out", out, in}
+	var stdout, stderr bytes.Buffer
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+	results := readResults(t, out)
+	if len(results) != 3 || results[1].ID != "2" || results[1].Line != 2 ||
+		!strings.Contains(results[1].Error, "invalid input") || results[2].Response == nil {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	// 修正该行后 -resume 只重新执行它.
+	input = strings.Replace(input, `{"id":"b","prompt":[`, `{"prompt":[{"role":"user","content":"second"}]}`, 1)
+	if err := os.WriteFile(in, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	args = append(args[:len(args)-1], "-resume", in)
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+	results = readResults(t, out)
+	if len(results) != 4 || results[3].ID != "2" || results[3].Error != "" || results[3].Response == nil {
+		t.Fatalf("malformed line was not retried: %+v", results)
+	}
+}
+
+func TestBatchRateLimitBounds(t *testing.T) {
+	server := newEchoServer(t)
+	defer server.Close()
+
+	in := filepath.Join(t.TempDir(), "in.jsonl")
+	if err := os.WriteFile(in, []byte(`{"prompt":[{"role":"user","content":"hi"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"batch", "-key", "id.secret", "-base-url", server.URL, "-quiet", "-rps", "1e12", in}
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+	args = []string{"batch", "-key", "id.secret", "-quiet", "-rps", "-1", in}
+	if code := run(args, nil, &stdout, &stderr); code != 2 {
+		t.Fatalf("expected usage error for negative -rps, got exit code %d", code)
+	}
+}
+
+func readResults(t *testing.T, path string) []batchResult {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []batchResult
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var r batchResult
+		if err = json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid output line %q: %v", line, err)
+		}
+		results = append(results, r)
+	}
+	return results
+}
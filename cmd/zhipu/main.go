@@ -0,0 +1,100 @@
+// Command zhipu 是智谱对话接口的命令行工具.
+//
+//	zhipu [flags]          交互式对话, 输入 /help 查看命令
+//	zhipu batch [flags]    批量执行 JSONL 文件中的请求
+//
+// 密钥通过 -key 或环境变量 ZHIPU_API_KEY 提供, 格式为 "id.secret".
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+const (
+	envAPIKey  = "ZHIPU_API_KEY"
+	envBaseURL = "ZHIPU_BASE_URL"
+	tokenTTL   = 12 * time.Hour
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// clientFlags 各子命令共用的连接参数.
+type clientFlags struct {
+	key     string
+	baseURL string
+	model   string
+	timeout time.Duration
+}
+
+func (f *clientFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.key, "key", os.Getenv(envAPIKey), "API key (id.secret), defaults to $"+envAPIKey)
+	fs.StringVar(&f.baseURL, "base-url", os.Getenv(envBaseURL), "API base URL, defaults to $"+envBaseURL+" or the Zhipu v3 endpoint")
+	fs.StringVar(&f.model, "model", zhipu.Turbo, "model name")
+	fs.DurationVar(&f.timeout, "timeout", 0, "HTTP client timeout, 0 means no timeout")
+}
+
+func (f *clientFlags) client() (zhipu.ChatCompletion[zhipu.ChatCompletionRequest], error) {
+	if f.key == "" {
+		return nil, errors.New("missing API key, set -key or $" + envAPIKey)
+	}
+	token, err := zhipu.GenerateToken(f.key, tokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	config := zhipu.DefaultConfig(token)
+	if f.baseURL != "" {
+		config.BaseURL = f.baseURL
+		if config.BaseURL[len(config.BaseURL)-1] != '/' {
+			config.BaseURL += "/"
+		}
+	}
+	config.HTTPClient.Timeout = f.timeout
+	return zhipu.NewClientWithConfig(config), nil
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "batch" {
+		return runBatch(args[1:], stdin, stdout, stderr)
+	}
+
+	fs := flag.NewFlagSet("zhipu", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var (
+		cf          clientFlags
+		system      string
+		temperature float64
+	)
+	cf.register(fs)
+	fs.StringVar(&system, "system", "", "system prompt")
+	fs.Float64Var(&temperature, "temperature", 0, "sampling temperature in (0, 1], 0 uses the model default")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	client, err := cf.client()
+	if err != nil {
+		fmt.Fprintln(stderr, "zhipu:", err)
+		return 1
+	}
+
+	r := newREPL(client, stdin, stdout)
+	r.session.Model = cf.model
+	r.session.System = system
+	r.session.Temperature = float32(temperature)
+	if err = r.run(); err != nil {
+		fmt.Fprintln(stderr, "zhipu:", err)
+		return 1
+	}
+	return 0
+}
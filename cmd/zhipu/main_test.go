@@ -0,0 +1,84 @@
+package main //nolint:testpackage // testing unexported run entrypoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+// newEchoServer 以流式接口返回最后一条消息的内容.
+func newEchoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req zhipu.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		last := req.Messages[len(req.Messages)-1].Content
+		if strings.HasSuffix(r.URL.Path, "/invoke") {
+			content, _ := json.Marshal("echo: " + last)
+			fmt.Fprintf(w, `{"code":200,"success":true,"data":{"choices":[{"role":"assistant","content":%s}],`+
+				`"usage":{"total_tokens":%d}}}`, content, len(req.Messages))
+			return
+		}
+		fmt.Fprintf(w, "event:add\nid:1\ndata:echo(%s): %s\n\n", req.Model, strings.ReplaceAll(last, "\n", " "))
+		fmt.Fprintf(w, "event:finish\nid:1\ndata:\nmeta:{\"usage\":{\"total_tokens\":%d}}\n\n", len(req.Messages))
+	}))
+}
+
+func TestInteractiveChat(t *testing.T) {
+	server := newEchoServer(t)
+	defer server.Close()
+
+	saved := filepath.Join(t.TempDir(), "session.json")
+	input := strings.Join([]string{
+		"/system be brief",
+		"/model glm-4",
+		"hello",
+		`"""`,
+		"line one",
+		"line two",
+		`"""`,
+		"/save " + saved,
+		"/reset",
+		"/load " + saved,
+		"/temperature 2",
+		"/exit",
+	}, "\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-key", "id.secret", "-base-url", server.URL}, strings.NewReader(input), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+
+	out := stdout.String()
+	for _, want := range []string{
+		"echo(glm-4): hello",
+		"[tokens: 2]",
+		"echo(glm-4): line one line two",
+		"[tokens: 4]",
+		"saved 4 messages",
+		"loaded 4 messages",
+		"invalid temperature",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestMissingKey(t *testing.T) {
+	t.Setenv(envAPIKey, "")
+	var stdout, stderr bytes.Buffer
+	if code := run(nil, strings.NewReader(""), &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "missing API key") {
+		t.Fatalf("unexpected exit code %d, stderr: %s", code, stderr.String())
+	}
+}
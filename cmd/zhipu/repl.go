@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+const (
+	prompt         = ">>> "
+	continuePrompt = "... "
+	blockDelimiter = `"""`
+)
+
+const helpText = `Commands:
+  /model <name>          switch model
+  /system [text]         set the system prompt, empty clears it
+  /temperature <value>   set temperature, 0 uses the model default
+  /reset                 clear the conversation
+  /save <file>           save the session as JSON
+  /load <file>           load a session saved by /save
+  /help                  show this help
+  /exit                  quit
+End a line with \ to continue it, or wrap multi-line input in """.`
+
+// session 对话状态, 也是 /save 与 /load 的文件格式.
+type session struct {
+	Model       string                        `json:"model"`
+	System      string                        `json:"system,omitempty"`
+	Temperature float32                       `json:"temperature,omitempty"`
+	Messages    []zhipu.ChatCompletionMessage `json:"messages"`
+}
+
+type repl struct {
+	client  zhipu.ChatCompletion[zhipu.ChatCompletionRequest]
+	in      *bufio.Scanner
+	out     io.Writer
+	session session
+}
+
+func newREPL(client zhipu.ChatCompletion[zhipu.ChatCompletionRequest], in io.Reader, out io.Writer) *repl {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return &repl{client: client, in: scanner, out: out}
+}
+
+func (r *repl) run() error {
+	for {
+		input, ok := r.readInput()
+		if !ok {
+			return r.in.Err()
+		}
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+
+		if strings.HasPrefix(input, "/") {
+			quit, err := r.command(input)
+			if err != nil {
+				fmt.Fprintln(r.out, "error:", err)
+			}
+			if quit {
+				return nil
+			}
+			continue
+		}
+
+		if err := r.chat(input); err != nil {
+			fmt.Fprintln(r.out, "\nerror:", err)
+		}
+	}
+}
+
+// readInput 读取一条输入, 支持以 \ 结尾的续行和 """ 包围的多行块.
+func (r *repl) readInput() (string, bool) {
+	fmt.Fprint(r.out, prompt)
+	if !r.in.Scan() {
+		return "", false
+	}
+	line := r.in.Text()
+
+	if strings.TrimSpace(line) == blockDelimiter {
+		var lines []string
+		for {
+			fmt.Fprint(r.out, continuePrompt)
+			if !r.in.Scan() {
+				return strings.Join(lines, "\n"), len(lines) > 0
+			}
+			if strings.TrimSpace(r.in.Text()) == blockDelimiter {
+				return strings.Join(lines, "\n"), true
+			}
+			lines = append(lines, r.in.Text())
+		}
+	}
+
+	var b strings.Builder
+	for strings.HasSuffix(line, `\`) {
+		b.WriteString(strings.TrimSuffix(line, `\`))
+		b.WriteByte('\n')
+		fmt.Fprint(r.out, continuePrompt)
+		if !r.in.Scan() {
+			return b.String(), true
+		}
+		line = r.in.Text()
+	}
+	b.WriteString(line)
+	return b.String(), true
+}
+
+func (r *repl) command(input string) (quit bool, err error) {
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "/exit", "/quit":
+		return true, nil
+	case "/help":
+		fmt.Fprintln(r.out, helpText)
+	case "/model":
+		if arg == "" {
+			fmt.Fprintln(r.out, "model:", r.session.Model)
+			return false, nil
+		}
+		r.session.Model = arg
+	case "/system":
+		r.session.System = arg
+	case "/temperature":
+		t, parseErr := strconv.ParseFloat(arg, 32)
+		if parseErr != nil || t < 0 || t > 1 {
+			return false, fmt.Errorf("invalid temperature %q, want a value in [0, 1]", arg)
+		}
+		r.session.Temperature = float32(t)
+	case "/reset":
+		r.session.Messages = nil
+	case "/save":
+		return false, r.save(arg)
+	case "/load":
+		return false, r.load(arg)
+	default:
+		return false, fmt.Errorf("unknown command %s, type /help", name)
+	}
+	return false, nil
+}
+
+func (r *repl) save(path string) error {
+	if path == "" {
+		return errors.New("usage: /save <file>")
+	}
+	data, err := json.MarshalIndent(r.session, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintf(r.out, "saved %d messages to %s\n", len(r.session.Messages), path)
+	return nil
+}
+
+func (r *repl) load(path string) error {
+	if path == "" {
+		return errors.New("usage: /load <file>")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var s session
+	if err = json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s.Model == "" {
+		s.Model = r.session.Model
+	}
+	r.session = s
+	fmt.Fprintf(r.out, "loaded %d messages from %s\n", len(s.Messages), path)
+	return nil
+}
+
+func (r *repl) request() zhipu.ChatCompletionRequest {
+	messages := make([]zhipu.ChatCompletionMessage, 0, len(r.session.Messages)+1)
+	if r.session.System != "" {
+		messages = append(messages, zhipu.ChatCompletionMessage{Role: zhipu.ChatMessageRoleSystem, Content: r.session.System})
+	}
+	request := zhipu.ChatCompletionRequest{
+		Model:       r.session.Model,
+		Messages:    append(messages, r.session.Messages...),
+		Incremental: true,
+	}
+	if r.session.Temperature > 0 {
+		request.Temperature = zhipu.Float32(r.session.Temperature)
+	}
+	return request
+}
+
+// chat 发送一轮对话并流式输出回答, Ctrl-C 中断当前回答.
+func (r *repl) chat(input string) error {
+	r.session.Messages = append(r.session.Messages, zhipu.ChatCompletionMessage{Role: zhipu.ChatMessageRoleUser, Content: input})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	stream, err := r.client.CreateChatCompletionStream(ctx, r.request())
+	if err != nil {
+		r.session.Messages = r.session.Messages[:len(r.session.Messages)-1]
+		return err
+	}
+	defer stream.Close()
+
+	var (
+		answer strings.Builder
+		usage  int
+	)
+	for {
+		resp, recvErr := stream.Recv()
+		if errors.Is(recvErr, io.EOF) {
+			break
+		}
+		if recvErr != nil {
+			err = recvErr
+			break
+		}
+		for _, choice := range resp.Choices {
+			answer.WriteString(choice.Delta.Content)
+			fmt.Fprint(r.out, choice.Delta.Content)
+		}
+		if resp.Meta.Usage.TotalTokens > 0 {
+			usage = resp.Meta.Usage.TotalTokens
+		}
+	}
+	fmt.Fprintln(r.out)
+
+	if answer.Len() > 0 {
+		r.session.Messages = append(r.session.Messages, zhipu.ChatCompletionMessage{
+			Role:    zhipu.ChatMessageRoleAssistant,
+			Content: answer.String(),
+		})
+	} else {
+		r.session.Messages = r.session.Messages[:len(r.session.Messages)-1]
+	}
+	if usage > 0 {
+		fmt.Fprintf(r.out, "[tokens: %d]\n", usage)
+	}
+	return err
+}
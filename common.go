@@ -7,5 +7,10 @@ type Usage struct {
 }
 
 const (
-	Turbo = "chatglm_turbo"
+	Turbo      = "chatglm_turbo"
+	GLM4       = "glm-4"
+	GLM4V      = "glm-4v"
+	GLM3Turbo  = "glm-3-turbo"
+	Embedding2 = "embedding-2"
+	CogView3   = "cogview-3"
 )
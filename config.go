@@ -2,10 +2,14 @@ package zhipu
 
 import (
 	"net/http"
+	"time"
+
+	"github.com/gtkit/go-zhipu/cache"
 )
 
 const (
 	glmaiAPIURLv1 = "https://open.bigmodel.cn/api/paas/v3/model-api/"
+	glmaiAPIURLv4 = "https://open.bigmodel.cn/api/paas/v4/"
 )
 
 // ClientConfig is a configuration of a client.
@@ -13,6 +17,31 @@ type ClientConfig struct {
 	authToken  string
 	BaseURL    string
 	HTTPClient *http.Client
+	// KeyPool 设置后每次请求从密钥池中选择密钥签名, 忽略 authToken.
+	KeyPool *KeyPool
+	// Backends 按顺序尝试的后端, 上游暂时不可用时切换到下一个; 为空时只使用 BaseURL 对应的智谱 v3 接口.
+	Backends []Backend
+	// OnBackendError 后端调用失败时回调, 可用于记录故障切换.
+	OnBackendError func(backend string, err error)
+	// Cache 设置后相同的请求直接返回缓存的结果, 流式请求命中时回放缓存内容.
+	// 只有非流式调用的结果会写入缓存, 流式调用只读取.
+	Cache cache.Store
+	// CacheTTL 缓存有效期, 0 表示不过期.
+	CacheTTL time.Duration
+	// UsageTracker 设置后记录每次调用的 tokens 与费用, 流式调用在返回用量的 finish 事件时记录.
+	UsageTracker *UsageTracker
+	// Budget 设置后通过 WithTenant 标记租户的调用受额度限制.
+	Budget *BudgetEnforcer
+	// Models 请求校验使用的模型能力表, 为 nil 时使用 DefaultModels.
+	Models ModelTable
+	// DisableValidation 为 true 时发送前不校验请求.
+	DisableValidation bool
+	// StreamTimeouts 流式调用首个事件、事件间隔与总时长的超时, 默认不限制.
+	StreamTimeouts StreamTimeouts
+	// Hedge 设置后对慢请求发送对冲请求, 流式调用以收到第一个事件为准.
+	Hedge *HedgePolicy
+	// CircuitBreaker 设置后按后端、模型与接口熔断, 打开时请求返回 ErrCircuitOpen 或切换到下一个后端.
+	CircuitBreaker *CircuitBreaker
 }
 
 func DefaultConfig(authToken string) ClientConfig {
@@ -23,6 +52,13 @@ func DefaultConfig(authToken string) ClientConfig {
 	}
 }
 
+// DefaultConfigWithKeyPool 使用密钥池签名请求的默认配置.
+func DefaultConfigWithKeyPool(pool *KeyPool) ClientConfig {
+	config := DefaultConfig("")
+	config.KeyPool = pool
+	return config
+}
+
 func (ClientConfig) String() string {
 	return "<GlmAI API ClientConfig>"
 }
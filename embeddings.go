@@ -0,0 +1,119 @@
+package zhipu
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	// TextEmbedding 智谱 v3 向量模型.
+	TextEmbedding = "text_embedding"
+
+	openAIEmbeddingsSuffix = "embeddings"
+)
+
+// EmbeddingRequest 向量化请求.
+type EmbeddingRequest struct {
+	Model  string `json:"-"`
+	Prompt string `json:"prompt"`
+}
+
+// EmbeddingResponse 向量化结果.
+type EmbeddingResponse struct {
+	Model     string    `json:"model"`
+	Embedding []float64 `json:"embedding"`
+	Usage     Usage     `json:"usage"`
+	// Backend 实际提供该结果的后端名称.
+	Backend string `json:"backend,omitempty"`
+}
+
+// glmEmbeddingResponse 智谱 v3 向量接口响应.
+type glmEmbeddingResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		Embedding []float64 `json:"embedding"`
+		Usage     Usage     `json:"usage"`
+	} `json:"data"`
+	Success bool `json:"success"`
+}
+
+func (r *glmEmbeddingResponse) responseError() error {
+	return (&ChatglmCompletionResponse{Code: r.Code, Msg: r.Msg, Success: r.Success}).responseError()
+}
+
+// openAIEmbeddingResponse 兼容 OpenAI 的向量接口响应.
+type openAIEmbeddingResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+	Usage Usage `json:"usage"`
+}
+
+// CreateEmbeddings 获取文本向量, 与对话接口一样按顺序尝试配置的后端.
+func (c *Client) CreateEmbeddings(ctx context.Context, request EmbeddingRequest) (response EmbeddingResponse, err error) {
+	if request.Model == "" {
+		request.Model = TextEmbedding
+	}
+
+	for _, backend := range c.backends() {
+		response, err = c.createEmbeddings(ctx, backend, request)
+		if err == nil {
+			response.Backend = backend.Name
+			c.recordUsage(ctx, request.Model, response.Usage)
+			return
+		}
+		if !c.reportBackendError(ctx, backend, err) {
+			return
+		}
+	}
+	return
+}
+
+func (c *Client) createEmbeddings(
+	ctx context.Context,
+	backend Backend,
+	request EmbeddingRequest,
+) (response EmbeddingResponse, err error) {
+	model := backend.model(request.Model)
+	endpoint := chatCompletionsSuffix
+	if backend.Kind != BackendZhipuV3 {
+		endpoint = openAIEmbeddingsSuffix
+	}
+	probe, err := c.allowCircuit(backend, model, endpoint)
+	if err != nil {
+		return
+	}
+	defer func() { probe.done(err) }()
+
+	if backend.Kind != BackendZhipuV3 {
+		body := map[string]string{"model": model, "input": request.Prompt}
+		req, reqErr := c.newRequest(ctx, http.MethodPost, backend.baseURL()+openAIEmbeddingsSuffix, withBody(body))
+		if reqErr != nil {
+			return response, reqErr
+		}
+		c.setBackendAuth(req, backend)
+
+		var resp openAIEmbeddingResponse
+		if err = c.sendRequest(req, &resp); err != nil {
+			return
+		}
+		if len(resp.Data) == 0 {
+			return response, ErrNoChoices
+		}
+		return EmbeddingResponse{Model: model, Embedding: resp.Data[0].Embedding, Usage: resp.Usage}, nil
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, backend.baseURL()+model+chatCompletionsSuffix, withBody(request))
+	if err != nil {
+		return
+	}
+	c.setBackendAuth(req, backend)
+
+	var resp glmEmbeddingResponse
+	if err = c.sendRequest(req, &resp); err != nil {
+		return
+	}
+	return EmbeddingResponse{Model: model, Embedding: resp.Data.Embedding, Usage: resp.Data.Usage}, nil
+}
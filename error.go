@@ -3,6 +3,7 @@ package zhipu
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -34,6 +35,19 @@ func (e *APIError) Error() string {
 	return e.Message
 }
 
+// ZhipuCode 返回智谱业务错误码, 兼容数字与字符串两种格式, 无法解析时返回 0.
+func (e *APIError) ZhipuCode() int {
+	switch code := e.Code.(type) {
+	case int:
+		return code
+	case string:
+		n, _ := strconv.Atoi(code)
+		return n
+	default:
+		return 0
+	}
+}
+
 func (e *APIError) UnmarshalJSON(data []byte) (err error) {
 	var rawMap map[string]json.RawMessage
 	err = json.Unmarshal(data, &rawMap)
@@ -0,0 +1,266 @@
+package zhipu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HedgePolicy 对冲请求策略: 请求在 delay 内没有返回(流式调用为没有收到第一个事件)时,
+// 再发送一个相同的请求, 使用先成功返回的结果并取消另一个. 可以被多个客户端共用.
+// 未被采用但已成功返回的非流式请求仍计入 UsageTracker; 被取消的请求与被关闭的流拿不到用量,
+// 上游可能已经为其计费, 这部分不会计入 UsageTracker 与 Budget.
+type HedgePolicy struct {
+	delay        time.Duration
+	maxPerSecond int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	requests  atomic.Int64
+	hedged    atomic.Int64
+	hedgeWins atomic.Int64
+	throttled atomic.Int64
+}
+
+// HedgeStats 对冲请求的统计.
+type HedgeStats struct {
+	// Requests 受策略控制的请求数.
+	Requests int64 `json:"requests"`
+	// Hedged 发送了对冲请求的次数.
+	Hedged int64 `json:"hedged"`
+	// HedgeWon 对冲请求先于原请求成功返回的次数.
+	HedgeWon int64 `json:"hedge_won"`
+	// Throttled 达到每秒上限而没有发送对冲请求的次数.
+	Throttled int64 `json:"throttled"`
+}
+
+// NewHedgePolicy 创建对冲策略, maxPerSecond 为每秒最多发送的对冲请求数, 0 表示不限制.
+func NewHedgePolicy(delay time.Duration, maxPerSecond int) *HedgePolicy {
+	return &HedgePolicy{
+		delay:        delay,
+		maxPerSecond: maxPerSecond,
+		tokens:       float64(maxPerSecond),
+		last:         time.Now(),
+	}
+}
+
+// Stats 返回当前的统计.
+func (p *HedgePolicy) Stats() HedgeStats {
+	return HedgeStats{
+		Requests:  p.requests.Load(),
+		Hedged:    p.hedged.Load(),
+		HedgeWon:  p.hedgeWins.Load(),
+		Throttled: p.throttled.Load(),
+	}
+}
+
+// allow 按令牌桶限制每秒的对冲请求数.
+func (p *HedgePolicy) allow() bool {
+	if p.maxPerSecond <= 0 {
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	p.tokens += now.Sub(p.last).Seconds() * float64(p.maxPerSecond)
+	if p.tokens > float64(p.maxPerSecond) {
+		p.tokens = float64(p.maxPerSecond)
+	}
+	p.last = now
+	if p.tokens < 1 {
+		return false
+	}
+	p.tokens--
+	return true
+}
+
+type hedgeResult[T any] struct {
+	value T
+	err   error
+	// index 请求的序号, 0 为原请求.
+	index int
+}
+
+// hedge 执行 attempt, 超过策略的等待时间仍未返回时再并发执行一次, 返回先成功的结果与其 context 的 cancel.
+// 未被采用但成功返回的结果交给 discard 释放. 都失败时优先返回原请求的错误.
+func hedge[T any](
+	ctx context.Context,
+	policy *HedgePolicy,
+	attempt func(ctx context.Context) (T, error),
+	discard func(T),
+) (T, context.CancelFunc, error) {
+	policy.requests.Add(1)
+
+	results := make(chan hedgeResult[T], 2)
+	var cancels []context.CancelFunc
+	launch := func() {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			value, err := attempt(attemptCtx)
+			results <- hedgeResult[T]{value: value, err: err, index: index}
+		}()
+	}
+
+	launch()
+	pending := 1
+	timer := time.NewTimer(policy.delay)
+	defer timer.Stop()
+	timeout := timer.C
+
+	var failed *hedgeResult[T]
+	for pending > 0 {
+		select {
+		case <-timeout:
+			timeout = nil
+			if !policy.allow() {
+				policy.throttled.Add(1)
+				continue
+			}
+			policy.hedged.Add(1)
+			launch()
+			pending++
+		case result := <-results:
+			pending--
+			if result.err != nil {
+				cancels[result.index]()
+				if failed == nil || result.index == 0 {
+					result := result
+					failed = &result
+				}
+				continue
+			}
+
+			if result.index > 0 {
+				policy.hedgeWins.Add(1)
+			}
+			for i, cancel := range cancels {
+				if i != result.index {
+					cancel()
+				}
+			}
+			go drainHedge(results, pending, discard)
+			return result.value, cancels[result.index], nil
+		}
+	}
+	return failed.value, nil, failed.err
+}
+
+// drainHedge 等待未被采用的请求结束并释放其结果.
+func drainHedge[T any](results <-chan hedgeResult[T], pending int, discard func(T)) {
+	for ; pending > 0; pending-- {
+		result := <-results
+		if result.err == nil && discard != nil {
+			discard(result.value)
+		}
+	}
+}
+
+// hedgedChatCompletion 配置了 Hedge 时以对冲方式调用 createChatCompletion.
+func (c *Client) hedgedChatCompletion(
+	ctx context.Context,
+	backend Backend,
+	request ChatCompletionRequest,
+) (ChatCompletionResponse, error) {
+	if c.config.Hedge == nil {
+		return c.createChatCompletion(ctx, backend, request)
+	}
+	response, cancel, err := hedge(ctx, c.config.Hedge, func(ctx context.Context) (ChatCompletionResponse, error) {
+		return c.createChatCompletion(ctx, backend, request)
+	}, func(loser ChatCompletionResponse) {
+		c.recordUsage(ctx, request.Model, loser.Usage)
+	})
+	if err != nil {
+		return response, err
+	}
+	cancel()
+	return response, nil
+}
+
+// hedgedChatCompletionStream 配置了 Hedge 时以对冲方式建立流, 以收到第一个事件为返回.
+func (c *Client) hedgedChatCompletionStream(
+	ctx context.Context,
+	backend Backend,
+	request ChatCompletionRequest,
+) (*GlmChatCompletionStream, error) {
+	if c.config.Hedge == nil {
+		return c.createChatCompletionStream(ctx, backend, request)
+	}
+	stream, cancel, err := hedge(ctx, c.config.Hedge, func(ctx context.Context) (*GlmChatCompletionStream, error) {
+		stream, err := c.createChatCompletionStream(ctx, backend, request)
+		if err != nil {
+			return nil, err
+		}
+		if err = stream.awaitFirstEvent(); err != nil {
+			// 读取第一个事件失败也通知观察者, 熔断器据此记录结果.
+			stream.notify(GlmChatCompletionStreamResponse{}, err)
+			stream.Close()
+			return nil, err
+		}
+		return stream, nil
+	}, (*GlmChatCompletionStream).Close)
+	if err != nil {
+		return nil, err
+	}
+	stream.onClose = append(stream.onClose, cancel)
+	return stream, nil
+}
+
+// peekedBody 先返回预读的数据, 再从响应体读取.
+type peekedBody struct {
+	io.ReadCloser
+	buf []byte
+}
+
+func (b *peekedBody) Read(p []byte) (int, error) {
+	if len(b.buf) > 0 {
+		n := copy(p, b.buf)
+		b.buf = b.buf[n:]
+		return n, nil
+	}
+	return b.ReadCloser.Read(p)
+}
+
+// awaitFirstEvent 读取响应体直到第一个事件结束(空行)或连接结束, 读到的数据仍由 Recv 解析.
+// 受 StreamTimeouts.FirstEvent 限制.
+func (stream *streamReader[T]) awaitFirstEvent() error {
+	body := &peekedBody{ReadCloser: stream.response.Body}
+	if err := stream.deadline.arm(body.ReadCloser); err != nil {
+		return err
+	}
+
+	var err error
+	chunk := make([]byte, 4096)
+	for !hasEventBoundary(body.buf) && len(body.buf) < maxErrorBodySize {
+		var n int
+		n, err = body.ReadCloser.Read(chunk)
+		body.buf = append(body.buf, chunk[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	if timeoutErr := stream.deadline.disarm(err); timeoutErr != nil {
+		return timeoutErr
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	stream.response.Body = body
+	stream.setBody(body)
+	return nil
+}
+
+func hasEventBoundary(data []byte) bool {
+	return bytes.Contains(data, []byte("\n\n")) ||
+		bytes.Contains(data, []byte("\r\n\r\n")) ||
+		bytes.Contains(data, []byte("\r\r"))
+}
@@ -0,0 +1,71 @@
+package zhipu
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// MaxImageSize GLM-4V 单张图片大小上限.
+const MaxImageSize = 5 << 20
+
+var (
+	ErrImageTooLarge        = errors.New("image exceeds the maximum allowed size")
+	ErrUnsupportedImageType = errors.New("unsupported image type")
+)
+
+var supportedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// NewTextPart 返回一个文本消息片段.
+func NewTextPart(text string) ChatMessagePart {
+	return ChatMessagePart{
+		Type: ChatMessagePartTypeText,
+		Text: text,
+	}
+}
+
+// NewImageURLPart 返回一个引用远程图片地址的消息片段.
+func NewImageURLPart(url string) ChatMessagePart {
+	return ChatMessagePart{
+		Type:     ChatMessagePartTypeImageURL,
+		ImageURL: &ChatMessageImageURL{URL: url},
+	}
+}
+
+// NewImagePartFromFile 读取本地图片并编码为 base64 data URI 消息片段.
+func NewImagePartFromFile(path string) (ChatMessagePart, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ChatMessagePart{}, err
+	}
+	defer f.Close()
+
+	return NewImagePartFromReader(f)
+}
+
+// NewImagePartFromReader 读取图片数据, 通过内容嗅探判断 MIME 类型并编码为 base64 data URI 消息片段.
+// 超过 MaxImageSize 时返回 ErrImageTooLarge, 非 jpeg/png/gif/webp 时返回 ErrUnsupportedImageType.
+func NewImagePartFromReader(r io.Reader) (ChatMessagePart, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxImageSize+1))
+	if err != nil {
+		return ChatMessagePart{}, fmt.Errorf("read image, %w", err)
+	}
+	if len(data) > MaxImageSize {
+		return ChatMessagePart{}, ErrImageTooLarge
+	}
+
+	mimeType := http.DetectContentType(data)
+	if !supportedImageTypes[mimeType] {
+		return ChatMessagePart{}, fmt.Errorf("%w: %s", ErrUnsupportedImageType, mimeType)
+	}
+
+	return NewImageURLPart("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
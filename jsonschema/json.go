@@ -0,0 +1,190 @@
+// Package jsonschema 提供由 Go 类型生成 JSON Schema 以及基于 Schema 的校验,
+// 用于结构化输出和工具参数定义.
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type DataType string
+
+const (
+	Object  DataType = "object"
+	Number  DataType = "number"
+	Integer DataType = "integer"
+	String  DataType = "string"
+	Array   DataType = "array"
+	Null    DataType = "null"
+	Boolean DataType = "boolean"
+)
+
+var ErrRecursiveType = errors.New("jsonschema: recursive types are not supported")
+
+// Definition is a struct for describing a JSON Schema.
+// It is fairly limited, and you may have better luck using a third-party library.
+type Definition struct {
+	// Type specifies the data type of the schema.
+	Type DataType `json:"type,omitempty"`
+	// Description is the description of the schema.
+	Description string `json:"description,omitempty"`
+	// Enum is used to restrict a value to a fixed set of values.
+	Enum []string `json:"enum,omitempty"`
+	// Properties describes the properties of an object, if the schema type is Object.
+	Properties map[string]Definition `json:"properties,omitempty"`
+	// Required specifies which properties are required, if the schema type is Object.
+	Required []string `json:"required,omitempty"`
+	// Items specifies which data type an array contains, if the schema type is Array.
+	Items *Definition `json:"items,omitempty"`
+	// AdditionalProperties is used to control the handling of properties in an object
+	// that are not explicitly defined in the properties section of the schema.
+	AdditionalProperties any `json:"additionalProperties,omitempty"`
+	// Nullable 校验时接受 null. GenerateSchemaForType 为指针、切片与 map 类型的字段设置,
+	// 与 encoding/json 对 nil 值的编码一致; 不写入生成的 Schema.
+	Nullable bool `json:"-"`
+}
+
+func (d Definition) MarshalJSON() ([]byte, error) {
+	if d.Properties == nil && d.Type == Object {
+		d.Properties = make(map[string]Definition)
+	}
+	type Alias Definition
+	return json.Marshal(Alias(d))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// GenerateSchemaForType 根据 v 的类型生成 Schema.
+// 结构体字段名取自 json 标签, 没有 omitempty 的字段视为必填;
+// description 标签作为字段说明, enum 标签(逗号分隔)限定取值, required:"false" 可将字段标记为可选.
+func GenerateSchemaForType(v any) (*Definition, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, errors.New("jsonschema: cannot generate schema for nil")
+	}
+	d, err := reflectSchema(t, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+	// 顶层的值本身不允许为 null.
+	d.Nullable = false
+	return d, nil
+}
+
+// reflectSchema 生成 t 的 Schema, 指针、切片与 map 可以被编码为 null, 标记为 Nullable.
+func reflectSchema(t reflect.Type, visiting map[reflect.Type]bool) (*Definition, error) {
+	nullable := t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	d, err := reflectElemSchema(t, visiting)
+	if err != nil {
+		return nil, err
+	}
+	d.Nullable = nullable || t.Kind() == reflect.Slice || t.Kind() == reflect.Map
+	return d, nil
+}
+
+func reflectElemSchema(t reflect.Type, visiting map[reflect.Type]bool) (*Definition, error) {
+	if t == timeType {
+		return &Definition{Type: String}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Definition{Type: String}, nil
+	case reflect.Bool:
+		return &Definition{Type: Boolean}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Definition{Type: Integer}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Definition{Type: Number}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := reflectSchema(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Definition{Type: Array, Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("jsonschema: unsupported map key type %s", t.Key())
+		}
+		values, err := reflectSchema(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Definition{Type: Object, AdditionalProperties: values}, nil
+	case reflect.Interface:
+		return &Definition{}, nil
+	case reflect.Struct:
+		if visiting[t] {
+			return nil, fmt.Errorf("%w: %s", ErrRecursiveType, t)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		d := &Definition{Type: Object, Properties: make(map[string]Definition), AdditionalProperties: false}
+		if err := reflectStructFields(t, d, visiting); err != nil {
+			return nil, err
+		}
+		return d, nil
+	default:
+		return nil, fmt.Errorf("jsonschema: unsupported type %s", t)
+	}
+}
+
+func reflectStructFields(t reflect.Type, d *Definition, visiting map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// 匿名嵌入且没有指定名称的结构体字段展开到外层.
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := reflectStructFields(ft, d, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		item, err := reflectSchema(field.Type, visiting)
+		if err != nil {
+			return err
+		}
+		item.Description = field.Tag.Get("description")
+		if enum := field.Tag.Get("enum"); enum != "" {
+			item.Enum = strings.Split(enum, ",")
+		}
+		d.Properties[name] = *item
+
+		required := !strings.Contains(opts, "omitempty")
+		if field.Tag.Get("required") == "false" {
+			required = false
+		}
+		if required {
+			d.Required = append(d.Required, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package jsonschema_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gtkit/go-zhipu/jsonschema"
+)
+
+type weather struct {
+	City     string   `json:"city" description:"城市名称"`
+	Unit     string   `json:"unit" enum:"celsius,fahrenheit"`
+	Days     int      `json:"days,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	internal string
+}
+
+func TestGenerateSchemaForType(t *testing.T) {
+	schema, err := jsonschema.GenerateSchemaForType(weather{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"object","properties":{"city":{"type":"string","description":"城市名称"},` +
+		`"days":{"type":"integer"},"tags":{"type":"array","items":{"type":"string"}},` +
+		`"unit":{"type":"string","enum":["celsius","fahrenheit"]}},"required":["city","unit"],"additionalProperties":false}`
+	if string(got) != want {
+		t.Fatalf("unexpected schema:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := jsonschema.GenerateSchemaForType(&weather{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = schema.Validate([]byte(`{"city":"北京","unit":"celsius","days":3}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = schema.Validate([]byte(`{"unit":"kelvin","days":1.5}`))
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 3 {
+		t.Fatalf("expected 3 problems, got %v", err)
+	}
+
+	err = schema.Validate([]byte(`{"city":"北京","unit":"celsius","days":3,"wind":"strong"}`))
+	if !errors.As(err, &verr) || len(verr.Problems) != 1 || !strings.Contains(verr.Problems[0], "$.wind") {
+		t.Fatalf("expected unknown field problem, got %v", err)
+	}
+
+	counts, err := jsonschema.GenerateSchemaForType(map[string]int{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = counts.Validate([]byte(`{"a":1,"b":"two"}`)); !errors.As(err, &verr) || len(verr.Problems) != 1 {
+		t.Fatalf("expected map value problem, got %v", err)
+	}
+}
+
+type profile struct {
+	Name   *string        `json:"name"`
+	Tags   []string       `json:"tags"`
+	Labels map[string]int `json:"labels"`
+	Age    int            `json:"age"`
+}
+
+func TestValidateNullableFields(t *testing.T) {
+	schema, err := jsonschema.GenerateSchemaForType(&profile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// encoding/json 将 nil 指针、切片与 map 编码为 null.
+	data, err := json.Marshal(profile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = schema.Validate(data); err != nil {
+		t.Fatalf("nil fields should round-trip, got %v", err)
+	}
+
+	var verr *jsonschema.ValidationError
+	err = schema.Validate([]byte(`{"name":null,"tags":null,"labels":null,"age":null}`))
+	if !errors.As(err, &verr) || len(verr.Problems) != 1 || !strings.Contains(verr.Problems[0], "$.age") {
+		t.Fatalf("expected only the non-pointer field to reject null, got %v", err)
+	}
+	if err = schema.Validate([]byte(`null`)); err == nil {
+		t.Fatal("expected the top-level value to reject null")
+	}
+}
+
+type node struct {
+	Children []node `json:"children"`
+}
+
+func TestRecursiveType(t *testing.T) {
+	if _, err := jsonschema.GenerateSchemaForType(node{}); !errors.Is(err, jsonschema.ErrRecursiveType) {
+		t.Fatalf("expected ErrRecursiveType, got %v", err)
+	}
+}
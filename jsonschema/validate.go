@@ -0,0 +1,117 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ValidationError 列出数据不符合 Schema 的所有问题.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "jsonschema: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate 校验 data 是否符合 Schema, 包括类型、必填字段、枚举值以及 additionalProperties.
+func (d *Definition) Validate(data []byte) error {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("jsonschema: invalid json, %w", err)
+	}
+
+	var problems []string
+	d.validate("$", v, &problems)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (d *Definition) validate(path string, v any, problems *[]string) {
+	if v == nil {
+		if d.Type != "" && d.Type != Null && !d.Nullable {
+			*problems = append(*problems, fmt.Sprintf("%s: expected %s, got null", path, d.Type))
+		}
+		return
+	}
+
+	switch d.Type {
+	case Object:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected object", path))
+			return
+		}
+		for _, name := range d.Required {
+			if _, ok = obj[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s.%s: required field is missing", path, name))
+			}
+		}
+		for name, value := range obj {
+			if prop, ok := d.Properties[name]; ok {
+				prop.validate(path+"."+name, value, problems)
+				continue
+			}
+			switch extra := d.AdditionalProperties.(type) {
+			case *Definition:
+				extra.validate(path+"."+name, value, problems)
+			case Definition:
+				extra.validate(path+"."+name, value, problems)
+			case bool:
+				if !extra {
+					*problems = append(*problems, fmt.Sprintf("%s.%s: unknown field is not allowed", path, name))
+				}
+			}
+		}
+	case Array:
+		arr, ok := v.([]any)
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected array", path))
+			return
+		}
+		if d.Items != nil {
+			for i, item := range arr {
+				d.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+	case String:
+		s, ok := v.(string)
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected string", path))
+			return
+		}
+		if len(d.Enum) > 0 && !contains(d.Enum, s) {
+			*problems = append(*problems, fmt.Sprintf("%s: %q is not one of [%s]", path, s, strings.Join(d.Enum, ", ")))
+		}
+	case Integer:
+		n, ok := v.(json.Number)
+		if _, err := n.Int64(); !ok || err != nil {
+			*problems = append(*problems, fmt.Sprintf("%s: expected integer", path))
+		}
+	case Number:
+		if _, ok := v.(json.Number); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected number", path))
+		}
+	case Boolean:
+		if _, ok := v.(bool); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected boolean", path))
+		}
+	case Null:
+		*problems = append(*problems, fmt.Sprintf("%s: expected null", path))
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
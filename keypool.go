@@ -0,0 +1,245 @@
+package zhipu
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type KeyPoolStrategy int
+
+const (
+	// KeyPoolRoundRobin 依次轮换使用可用的密钥.
+	KeyPoolRoundRobin KeyPoolStrategy = iota
+	// KeyPoolLeastRecentlyThrottled 优先使用最久没有被限流的密钥.
+	KeyPoolLeastRecentlyThrottled
+)
+
+const (
+	defaultThrottleQuarantine = time.Minute
+	defaultAuthQuarantine     = 10 * time.Minute
+	defaultPoolTokenTTL       = time.Hour
+	// 剩余有效期不足时重新签发 token.
+	tokenRefreshMargin = time.Minute
+)
+
+var ErrNoAvailableKey = errors.New("no available api key in pool")
+
+// 智谱鉴权类与配额类错误码.
+var (
+	zhipuAuthErrorCodes = map[int]bool{
+		1000: true, // 身份验证失败
+		1001: true, // Header 中未收到 Authentication 参数
+		1002: true, // Authentication Token 非法
+		1003: true, // Authentication Token 已过期
+		1004: true, // 通过提供的 Token 的身份验证失败
+		1100: true, // 账户读写异常
+		1110: true, // 账户处于非活动状态
+		1111: true, // 账户不存在
+		1112: true, // 账户已被锁定
+		1113: true, // 账户已欠费
+	}
+	zhipuQuotaErrorCodes = map[int]bool{
+		1302: true, // API 请求并发数超过上限
+		1303: true, // API 请求频率超过上限
+		1304: true, // API 调用次数超过当日上限
+		1305: true, // 当前 API 请求过多
+	}
+)
+
+// KeyUsage 单个密钥的使用情况, Key 只包含密钥 ID 部分.
+type KeyUsage struct {
+	Key              string
+	Requests         int64
+	Failures         int64
+	Throttled        int64
+	AuthFailures     int64
+	LastUsed         time.Time
+	LastThrottled    time.Time
+	QuarantinedUntil time.Time
+}
+
+type pooledKey struct {
+	apiKey   string
+	token    string
+	tokenExp time.Time
+	usage    KeyUsage
+}
+
+// KeyPool 管理多个智谱 API 密钥, 每次请求选择一个可用密钥签发 token,
+// 返回鉴权或配额错误的密钥会被暂时隔离.
+type KeyPool struct {
+	mu   sync.Mutex
+	keys []*pooledKey
+	next int
+
+	strategy           KeyPoolStrategy
+	throttleQuarantine time.Duration
+	authQuarantine     time.Duration
+	tokenTTL           time.Duration
+	now                func() time.Time
+}
+
+type KeyPoolOption func(*KeyPool)
+
+func WithKeyPoolStrategy(strategy KeyPoolStrategy) KeyPoolOption {
+	return func(p *KeyPool) {
+		p.strategy = strategy
+	}
+}
+
+// WithKeyQuarantine 设置限流(配额)错误与鉴权错误后密钥的隔离时长.
+func WithKeyQuarantine(throttle, auth time.Duration) KeyPoolOption {
+	return func(p *KeyPool) {
+		p.throttleQuarantine = throttle
+		p.authQuarantine = auth
+	}
+}
+
+// WithKeyTokenTTL 设置由密钥签发的 token 有效期.
+func WithKeyTokenTTL(ttl time.Duration) KeyPoolOption {
+	return func(p *KeyPool) {
+		p.tokenTTL = ttl
+	}
+}
+
+// NewKeyPool 使用 "id.secret" 格式的密钥创建密钥池.
+func NewKeyPool(apiKeys []string, opts ...KeyPoolOption) (*KeyPool, error) {
+	if len(apiKeys) == 0 {
+		return nil, errors.New("密钥不能为空")
+	}
+
+	p := &KeyPool{
+		throttleQuarantine: defaultThrottleQuarantine,
+		authQuarantine:     defaultAuthQuarantine,
+		tokenTTL:           defaultPoolTokenTTL,
+		now:                time.Now,
+	}
+	for _, apiKey := range apiKeys {
+		id, _, ok := strings.Cut(apiKey, ".")
+		if !ok {
+			return nil, fmt.Errorf("密钥格式不正确: %s", id)
+		}
+		p.keys = append(p.keys, &pooledKey{apiKey: apiKey, usage: KeyUsage{Key: id}})
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// Usage 返回每个密钥的使用情况.
+func (p *KeyPool) Usage() []KeyUsage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	usage := make([]KeyUsage, len(p.keys))
+	for i, k := range p.keys {
+		usage[i] = k.usage
+	}
+	return usage
+}
+
+func (p *KeyPool) size() int {
+	return len(p.keys)
+}
+
+// acquire 选择一个未被隔离的密钥并返回其 token.
+func (p *KeyPool) acquire() (*pooledKey, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	key := p.pick(now)
+	if key == nil {
+		return nil, "", ErrNoAvailableKey
+	}
+
+	if key.token == "" || now.Add(tokenRefreshMargin).After(key.tokenExp) {
+		token, err := GenerateToken(key.apiKey, p.tokenTTL)
+		if err != nil {
+			return nil, "", err
+		}
+		key.token = token
+		key.tokenExp = now.Add(p.tokenTTL)
+	}
+
+	key.usage.Requests++
+	key.usage.LastUsed = now
+	return key, key.token, nil
+}
+
+func (p *KeyPool) pick(now time.Time) *pooledKey {
+	if p.strategy == KeyPoolLeastRecentlyThrottled {
+		var best *pooledKey
+		for _, k := range p.keys {
+			if now.Before(k.usage.QuarantinedUntil) {
+				continue
+			}
+			if best == nil || k.usage.LastThrottled.Before(best.usage.LastThrottled) ||
+				(k.usage.LastThrottled.Equal(best.usage.LastThrottled) && k.usage.LastUsed.Before(best.usage.LastUsed)) {
+				best = k
+			}
+		}
+		return best
+	}
+
+	for i := 0; i < len(p.keys); i++ {
+		k := p.keys[(p.next+i)%len(p.keys)]
+		if now.Before(k.usage.QuarantinedUntil) {
+			continue
+		}
+		p.next = (p.next + i + 1) % len(p.keys)
+		return k
+	}
+	return nil
+}
+
+// release 根据请求结果更新密钥状态, 鉴权与配额错误会隔离密钥.
+func (p *KeyPool) release(key *pooledKey, err error) {
+	if key == nil || err == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	key.usage.Failures++
+	switch {
+	case isAuthError(err):
+		key.usage.AuthFailures++
+		key.usage.QuarantinedUntil = now.Add(p.authQuarantine)
+		// 鉴权失败时丢弃缓存的 token, 解除隔离后重新签发.
+		key.token = ""
+	case isThrottleError(err):
+		key.usage.Throttled++
+		key.usage.LastThrottled = now
+		key.usage.QuarantinedUntil = now.Add(p.throttleQuarantine)
+	}
+}
+
+func isAuthError(err error) bool {
+	code, status := errorCodes(err)
+	return zhipuAuthErrorCodes[code] || status == http.StatusUnauthorized || status == http.StatusForbidden
+}
+
+func isThrottleError(err error) bool {
+	code, status := errorCodes(err)
+	return zhipuQuotaErrorCodes[code] || status == http.StatusTooManyRequests
+}
+
+func errorCodes(err error) (code, status int) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ZhipuCode(), apiErr.HTTPStatusCode
+	}
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return 0, reqErr.HTTPStatusCode
+	}
+	return 0, 0
+}
@@ -0,0 +1,31 @@
+package zhipu
+
+// ModelCapabilities 模型支持的能力与参数范围, 用于请求的客户端校验.
+type ModelCapabilities struct {
+	// Vision 支持图片输入.
+	Vision bool
+	// Tools 支持 function 工具调用, web_search 不受此限制.
+	Tools bool
+	// MaxTokens 最大输出 tokens, 0 表示不限制.
+	MaxTokens int
+}
+
+// ModelTable 模型名到能力的映射, 表中没有的模型只做通用校验.
+type ModelTable map[string]ModelCapabilities
+
+// DefaultModels 返回智谱对话模型的默认能力表副本, 以官网文档为准, 可按需修改.
+func DefaultModels() ModelTable {
+	return ModelTable{
+		Turbo:     {Tools: true, MaxTokens: 8192},
+		GLM3Turbo: {Tools: true, MaxTokens: 8192},
+		GLM4:      {Tools: true, MaxTokens: 8192},
+		GLM4V:     {Vision: true, MaxTokens: 1024},
+	}
+}
+
+func (c *Client) models() ModelTable {
+	if c.config.Models != nil {
+		return c.config.Models
+	}
+	return DefaultModels()
+}
@@ -0,0 +1,226 @@
+package zhipu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gtkit/go-zhipu/sse"
+)
+
+const (
+	openAIChatCompletionsSuffix = "chat/completions"
+	openAIStreamDone            = "[DONE]"
+)
+
+// openAIChatCompletionResponse 兼容 OpenAI 的对话补全响应, 智谱 v4 接口使用相同格式.
+type openAIChatCompletionResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index        int                   `json:"index"`
+		Message      ChatCompletionMessage `json:"message"`
+		FinishReason string                `json:"finish_reason"`
+	} `json:"choices"`
+	Usage     Usage             `json:"usage"`
+	WebSearch []WebSearchResult `json:"web_search,omitempty"`
+}
+
+// openAIChatCompletionChunk 兼容 OpenAI 的流式数据块.
+type openAIChatCompletionChunk struct {
+	ID        string              `json:"id"`
+	Created   int64               `json:"created"`
+	Model     string              `json:"model"`
+	Choices   []openAIChunkChoice `json:"choices"`
+	Usage     *Usage              `json:"usage,omitempty"`
+	WebSearch []WebSearchResult   `json:"web_search,omitempty"`
+}
+
+type openAIChunkChoice struct {
+	Index        int                             `json:"index"`
+	Delta        ChatCompletionStreamChoiceDelta `json:"delta"`
+	FinishReason string                          `json:"finish_reason"`
+}
+
+// openAIRequestBody 将请求转换为 OpenAI 格式: prompt 改为 messages, incremental 改为 stream.
+// 先编码再改写字段, 新增的请求参数无需在此同步.
+func openAIRequestBody(request ChatCompletionRequest, stream bool) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var body map[string]json.RawMessage
+	if err = json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+
+	body["messages"] = body["prompt"]
+	delete(body, "prompt")
+	delete(body, "incremental")
+	// OpenAI 使用 user 字段, 其余智谱专有参数不转发.
+	if user, ok := body["user_id"]; ok {
+		body["user"] = user
+	}
+	for _, key := range []string{"user_id", "request_id", "do_sample", "return_type", "sensitive_word_check"} {
+		delete(body, key)
+	}
+	if stream {
+		body["stream"] = json.RawMessage("true")
+	}
+	return body, nil
+}
+
+func (c *Client) newOpenAIRequest(
+	ctx context.Context,
+	backend Backend,
+	request ChatCompletionRequest,
+	stream bool,
+) (*http.Request, error) {
+	body, err := openAIRequestBody(request, stream)
+	if err != nil {
+		return nil, err
+	}
+
+	// OpenAI 默认不在流中返回用量, 需要显式请求.
+	if stream && backend.Kind == BackendOpenAI {
+		body["stream_options"] = json.RawMessage(`{"include_usage":true}`)
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, backend.baseURL()+openAIChatCompletionsSuffix, withBody(body))
+	if err != nil {
+		return nil, err
+	}
+	c.setBackendAuth(req, backend)
+	return req, nil
+}
+
+func (c *Client) createOpenAIChatCompletion(
+	ctx context.Context,
+	backend Backend,
+	request ChatCompletionRequest,
+) (response ChatCompletionResponse, err error) {
+	req, err := c.newOpenAIRequest(ctx, backend, request, false)
+	if err != nil {
+		return
+	}
+
+	var resp openAIChatCompletionResponse
+	if err = c.sendRequest(req, &resp); err != nil {
+		return
+	}
+	if len(resp.Choices) == 0 {
+		return response, ErrNoChoices
+	}
+
+	response = ChatCompletionResponse{
+		ID:        resp.ID,
+		Object:    resp.Object,
+		Created:   resp.Created,
+		Model:     resp.Model,
+		Choices:   make([]ChatCompletionChoice, 0, len(resp.Choices)),
+		Usage:     resp.Usage,
+		WebSearch: resp.WebSearch,
+	}
+	if response.Created == 0 {
+		response.Created = time.Now().Unix()
+	}
+	if response.Model == "" {
+		response.Model = request.Model
+	}
+	for _, choice := range resp.Choices {
+		response.Choices = append(response.Choices, ChatCompletionChoice{Message: choice.Message})
+	}
+	return
+}
+
+func (c *Client) createOpenAIChatCompletionStream(
+	ctx context.Context,
+	backend Backend,
+	request ChatCompletionRequest,
+) (*GlmChatCompletionStream, error) {
+	req, err := c.newOpenAIRequest(ctx, backend, request, true)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := sendRequestStream[GlmChatCompletionStreamResponse](c, req, &openAIChunkDecoder{})
+	if err != nil {
+		return nil, err
+	}
+	return &GlmChatCompletionStream{streamReader: resp}, nil
+}
+
+// openAIChunkDecoder 将 OpenAI 格式的数据块解码为智谱格式.
+// 带有 finish_reason 与 usage 的数据块作为 finish 事件; 只有 finish_reason 时先作为 add 事件返回其内容,
+// 随后没有 choices 的 usage 数据块(stream_options.include_usage)或 [DONE] 作为 finish 事件, 保证只有一个 finish 事件且带有用量.
+type openAIChunkDecoder struct {
+	// id 与 finishReason 为等待用量的结束原因.
+	id           string
+	finishReason string
+}
+
+func (d *openAIChunkDecoder) Decode(event sse.Event) (response GlmChatCompletionStreamResponse, done bool, err error) {
+	chunk, done, err := JSONStreamDecoder[openAIChatCompletionChunk]{}.Decode(event)
+	if errors.Is(err, io.EOF) && d.finishReason != "" {
+		return d.finish(d.id, nil, nil), true, nil
+	}
+	if err != nil {
+		return response, done, err
+	}
+
+	if len(chunk.Choices) == 0 && chunk.Usage != nil {
+		return d.finish(chunk.ID, chunk.Usage, chunk.WebSearch), true, nil
+	}
+
+	response = GlmChatCompletionStreamResponse{
+		ID:        chunk.ID,
+		Event:     "add",
+		Choices:   make([]ChatCompletionStreamChoice, 0, len(chunk.Choices)),
+		WebSearch: chunk.WebSearch,
+	}
+	response.Meta.TaskID = chunk.ID
+	response.Meta.WebSearch = chunk.WebSearch
+	if chunk.Usage != nil {
+		response.Meta.Usage.TotalTokens = chunk.Usage.TotalTokens
+	}
+	for _, choice := range chunk.Choices {
+		response.Choices = append(response.Choices, ChatCompletionStreamChoice{Delta: choice.Delta})
+		if choice.FinishReason == "" {
+			continue
+		}
+		if chunk.Usage != nil {
+			response.Event = "finish"
+			response.Meta.TaskStatus = choice.FinishReason
+		} else {
+			d.id, d.finishReason = chunk.ID, choice.FinishReason
+		}
+	}
+	return response, false, nil
+}
+
+// finish 返回等待用量的 finish 事件, 内容为空.
+func (d *openAIChunkDecoder) finish(id string, usage *Usage, webSearch []WebSearchResult) GlmChatCompletionStreamResponse {
+	if id == "" {
+		id = d.id
+	}
+	response := GlmChatCompletionStreamResponse{
+		ID:        id,
+		Event:     "finish",
+		Choices:   []ChatCompletionStreamChoice{{}},
+		WebSearch: webSearch,
+	}
+	response.Meta.TaskID = id
+	response.Meta.TaskStatus = d.finishReason
+	response.Meta.WebSearch = webSearch
+	if usage != nil {
+		response.Meta.Usage.TotalTokens = usage.TotalTokens
+	}
+	d.id, d.finishReason = "", ""
+	return response
+}
@@ -0,0 +1,43 @@
+package zhipu
+
+// ModelPrice 模型价格, 单位为人民币元.
+type ModelPrice struct {
+	// PromptPer1K 输入每千 tokens 价格.
+	PromptPer1K float64 `json:"prompt_per_1k"`
+	// CompletionPer1K 输出每千 tokens 价格, 响应只返回总 tokens 时按此价格计算全部 tokens.
+	CompletionPer1K float64 `json:"completion_per_1k"`
+	// PerImage 图片生成模型每张图片价格.
+	PerImage float64 `json:"per_image"`
+}
+
+// PricingTable 模型名到价格的映射.
+type PricingTable map[string]ModelPrice
+
+// DefaultPricing 返回智谱常用模型的默认价格表副本, 实际价格以官网为准, 可按需修改.
+func DefaultPricing() PricingTable {
+	return PricingTable{
+		Turbo:         {PromptPer1K: 0.005, CompletionPer1K: 0.005},
+		GLM3Turbo:     {PromptPer1K: 0.005, CompletionPer1K: 0.005},
+		GLM4:          {PromptPer1K: 0.1, CompletionPer1K: 0.1},
+		GLM4V:         {PromptPer1K: 0.1, CompletionPer1K: 0.1},
+		Embedding2:    {PromptPer1K: 0.0005, CompletionPer1K: 0.0005},
+		TextEmbedding: {PromptPer1K: 0.0005, CompletionPer1K: 0.0005},
+		CogView3:      {PerImage: 0.25},
+	}
+}
+
+// Cost 计算一次调用的费用, ok 为 false 表示价格表中没有该模型.
+func (p PricingTable) Cost(model string, usage Usage, images int) (cost float64, ok bool) {
+	price, ok := p[model]
+	if !ok {
+		return 0, false
+	}
+
+	if usage.PromptTokens+usage.CompletionTokens > 0 {
+		cost = float64(usage.PromptTokens)*price.PromptPer1K/1000 +
+			float64(usage.CompletionTokens)*price.CompletionPer1K/1000
+	} else {
+		cost = float64(usage.TotalTokens) * price.CompletionPer1K / 1000
+	}
+	return cost + float64(images)*price.PerImage, true
+}
@@ -0,0 +1,326 @@
+// Package prompt 基于 text/template 构建对话消息. 一个模板文件中用 {{role "system"}}、
+// {{role "user"}}、{{role "assistant"}} 划分消息, 可以包含其他模板作为片段,
+// 用 {{require "Name"}} 声明必填变量, 用 {{model "glm-4"}} 指定模型:
+//
+//	{{require "Question"}}{{model "glm-4"}}
+//	{{role "system"}}{{template "_persona" .}}
+//	{{role "user"}}<question>{{escape .Question}}</question>
+package prompt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("prompt: template not found")
+	ErrNoRole           = errors.New("prompt: content outside a role block")
+)
+
+// MissingVariablesError 执行模板时缺少 {{require}} 声明的变量, Names 列出全部缺少的变量.
+type MissingVariablesError struct {
+	Template string
+	Names    []string
+}
+
+func (e *MissingVariablesError) Error() string {
+	return fmt.Sprintf("prompt: template %s missing required variables: %s", e.Template, strings.Join(e.Names, ", "))
+}
+
+const (
+	directiveRole    = "role"
+	directiveModel   = "model"
+	directiveRequire = "require"
+)
+
+// Set 共享同一命名空间的一组模板, 模板之间可以通过 {{template "name" .}} 互相引用.
+// 解析完成后可以被多个 goroutine 并发使用.
+type Set struct {
+	root *template.Template
+	// marker 指令输出的标记前缀, 包含随机数, 用户输入无法伪造.
+	marker string
+}
+
+// NewSet 返回空的模板集合.
+func NewSet() *Set {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+
+	s := &Set{marker: "\x00" + hex.EncodeToString(nonce) + ":"}
+	s.root = template.New("").Option("missingkey=error").Funcs(template.FuncMap{
+		directiveRole:    s.role,
+		directiveModel:   s.model,
+		directiveRequire: func(...string) string { return "" },
+		"escape":         Escape,
+	})
+	return s
+}
+
+// ParseFS 解析 fsys 中匹配 patterns 的文件, 模板名为去掉扩展名的文件名.
+// 约定以 "_" 开头的文件为片段, 但任何模板都可以被包含.
+func ParseFS(fsys fs.FS, patterns ...string) (*Set, error) {
+	s := NewSet()
+	if err := s.ParseFS(fsys, patterns...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ParseFS 将 fsys 中匹配 patterns 的文件加入集合.
+func (s *Set) ParseFS(fsys fs.FS, patterns ...string) error {
+	for _, pattern := range patterns {
+		files, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			data, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return err
+			}
+			base := path.Base(file)
+			if _, err = s.Parse(strings.TrimSuffix(base, path.Ext(base)), string(data)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Funcs 添加模板函数, 需在解析使用这些函数的模板之前调用.
+func (s *Set) Funcs(funcs template.FuncMap) *Set {
+	s.root.Funcs(funcs)
+	return s
+}
+
+// Parse 解析名为 name 的模板并加入集合, 同名模板会被替换.
+func (s *Set) Parse(name, text string) (*Template, error) {
+	if _, err := s.root.New(name).Parse(text); err != nil {
+		return nil, err
+	}
+	return &Template{set: s, name: name}, nil
+}
+
+// Lookup 返回名为 name 的模板, 不存在时返回 nil.
+func (s *Set) Lookup(name string) *Template {
+	if s.root.Lookup(name) == nil {
+		return nil
+	}
+	return &Template{set: s, name: name}
+}
+
+// Messages 执行名为 name 的模板, 返回其中的消息.
+func (s *Set) Messages(name string, data any) ([]zhipu.ChatCompletionMessage, error) {
+	t := s.Lookup(name)
+	if t == nil {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	return t.Messages(data)
+}
+
+// Request 执行名为 name 的模板, 返回对话请求.
+func (s *Set) Request(name string, data any) (zhipu.ChatCompletionRequest, error) {
+	t := s.Lookup(name)
+	if t == nil {
+		return zhipu.ChatCompletionRequest{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	return t.Request(data)
+}
+
+func (s *Set) role(role string) (string, error) {
+	switch role {
+	case zhipu.ChatMessageRoleSystem, zhipu.ChatMessageRoleUser, zhipu.ChatMessageRoleAssistant:
+		return s.marker + directiveRole + ":" + role + "\x00", nil
+	default:
+		return "", fmt.Errorf("prompt: unknown role %q", role)
+	}
+}
+
+func (s *Set) model(model string) string {
+	return s.marker + directiveModel + ":" + model + "\x00"
+}
+
+// Template 集合中的一个模板.
+type Template struct {
+	set  *Set
+	name string
+}
+
+func (t *Template) Name() string {
+	return t.name
+}
+
+// Required 返回模板及其包含的片段中 {{require}} 声明的变量.
+func (t *Template) Required() []string {
+	var names []string
+	seen := map[string]bool{}
+	t.set.walk(t.name, map[string]bool{}, func(node *parse.CommandNode) {
+		for _, arg := range node.Args[1:] {
+			if s, ok := arg.(*parse.StringNode); ok && !seen[s.Text] {
+				seen[s.Text] = true
+				names = append(names, s.Text)
+			}
+		}
+	})
+	return names
+}
+
+// Messages 执行模板, 返回按 {{role}} 划分的消息, 内容为空的消息会被忽略.
+func (t *Template) Messages(data any) ([]zhipu.ChatCompletionMessage, error) {
+	messages, _, err := t.execute(data)
+	return messages, err
+}
+
+// Request 执行模板, 返回对话请求; 模板未声明 {{model}} 时 Model 为空, 需由调用方设置.
+func (t *Template) Request(data any) (zhipu.ChatCompletionRequest, error) {
+	messages, model, err := t.execute(data)
+	if err != nil {
+		return zhipu.ChatCompletionRequest{}, err
+	}
+	return zhipu.ChatCompletionRequest{Model: model, Messages: messages}, nil
+}
+
+func (t *Template) execute(data any) (messages []zhipu.ChatCompletionMessage, model string, err error) {
+	if missing := missingVariables(data, t.Required()); len(missing) > 0 {
+		return nil, "", &MissingVariablesError{Template: t.name, Names: missing}
+	}
+
+	var out bytes.Buffer
+	if err = t.set.root.ExecuteTemplate(&out, t.name, data); err != nil {
+		return nil, "", err
+	}
+
+	var (
+		marker  = t.set.marker
+		text    = out.String()
+		role    string
+		content strings.Builder
+	)
+	// finish 结束当前消息, 第一个 {{role}} 之前只允许空白.
+	finish := func() error {
+		c := strings.TrimSpace(content.String())
+		content.Reset()
+		if role == "" && c != "" {
+			return ErrNoRole
+		}
+		if c != "" {
+			messages = append(messages, zhipu.ChatCompletionMessage{Role: role, Content: c})
+		}
+		return nil
+	}
+
+	for {
+		i := strings.Index(text, marker)
+		if i < 0 {
+			content.WriteString(text)
+			return messages, model, finish()
+		}
+		content.WriteString(text[:i])
+
+		text = text[i+len(marker):]
+		end := strings.IndexByte(text, 0)
+		kind, value, _ := strings.Cut(text[:end], ":")
+		text = text[end+1:]
+
+		switch kind {
+		case directiveRole:
+			if err = finish(); err != nil {
+				return nil, "", err
+			}
+			role = value
+		case directiveModel:
+			model = value
+		}
+	}
+}
+
+// walk 遍历模板及其通过 {{template}} 包含的模板, 对每个 {{require}} 调用 fn.
+func (s *Set) walk(name string, visited map[string]bool, fn func(*parse.CommandNode)) {
+	if visited[name] {
+		return
+	}
+	visited[name] = true
+	tmpl := s.root.Lookup(name)
+	if tmpl == nil || tmpl.Tree == nil {
+		return
+	}
+
+	var visit func(node parse.Node)
+	visit = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				visit(child)
+			}
+		case *parse.ActionNode:
+			for _, cmd := range n.Pipe.Cmds {
+				if id, ok := cmd.Args[0].(*parse.IdentifierNode); ok && id.Ident == directiveRequire {
+					fn(cmd)
+				}
+			}
+		case *parse.IfNode:
+			visit(n.List)
+			visit(n.ElseList)
+		case *parse.RangeNode:
+			visit(n.List)
+			visit(n.ElseList)
+		case *parse.WithNode:
+			visit(n.List)
+			visit(n.ElseList)
+		case *parse.TemplateNode:
+			s.walk(n.Name, visited, fn)
+		}
+	}
+	visit(tmpl.Tree.Root)
+}
+
+// missingVariables 返回 data 中不存在或为 nil 的变量, data 可以是 map 或结构体.
+// 0、false 与 "" 等零值是调用方提供的有效值, 不视为缺少.
+func missingVariables(data any, names []string) []string {
+	var missing []string
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	for _, name := range names {
+		var field reflect.Value
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() == reflect.String {
+				field = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			}
+		case reflect.Struct:
+			field = v.FieldByName(name)
+		}
+		if field.IsValid() && field.Kind() == reflect.Interface && !field.IsNil() {
+			field = field.Elem()
+		}
+		if !field.IsValid() || ((field.Kind() == reflect.Pointer || field.Kind() == reflect.Interface) && field.IsNil()) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "```", "'''", "\x00", "")
+
+// Escape 转义用户输入, 使其不能提前结束 <tag>...</tag> 或 ``` 代码块划分的区域.
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,364 @@
+// Package proxy 提供兼容 OpenAI 接口的 http.Handler, 将请求转发为智谱调用,
+// 便于只支持 OpenAI API 的工具直接使用.
+package proxy
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+const (
+	chatCompletionsPath = "/v1/chat/completions"
+	embeddingsPath      = "/v1/embeddings"
+	maxRequestBody      = 8 << 20
+	streamDone          = "[DONE]"
+)
+
+type handler struct {
+	client  zhipu.ChatCompletion[zhipu.ChatCompletionRequest]
+	apiKeys []string
+	models  map[string]string
+	mux     *http.ServeMux
+}
+
+type Option func(*handler)
+
+// WithAPIKeys 要求请求携带其中一个 Bearer 密钥, 未设置时不校验.
+func WithAPIKeys(keys ...string) Option {
+	return func(h *handler) {
+		h.apiKeys = append(h.apiKeys, keys...)
+	}
+}
+
+// WithModelAliases 将 OpenAI 请求中的模型名映射为智谱模型名, 例如 "gpt-4" -> "glm-4".
+func WithModelAliases(aliases map[string]string) Option {
+	return func(h *handler) {
+		h.models = aliases
+	}
+}
+
+// NewHandler 返回提供 /v1/chat/completions 与 /v1/embeddings 的 http.Handler.
+func NewHandler(client zhipu.ChatCompletion[zhipu.ChatCompletionRequest], opts ...Option) http.Handler {
+	h := &handler{client: client, mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.mux.HandleFunc(chatCompletionsPath, h.chatCompletions)
+	h.mux.HandleFunc(embeddingsPath, h.embeddings)
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "Incorrect API key provided.")
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *handler) authorized(r *http.Request) bool {
+	if len(h.apiKeys) == 0 {
+		return true
+	}
+	key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	for _, k := range h.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *handler) model(model string) string {
+	if m, ok := h.models[model]; ok {
+		return m
+	}
+	return model
+}
+
+// decodeChatRequest 将 OpenAI 请求转换为智谱请求: messages 改为 prompt, stream 改为 incremental, user 改为 user_id.
+// OpenAI 的 temperature 范围为 [0, 2], 智谱为 (0, 1]: 大于 1 时按 1 处理, 为 0 时改为 do_sample=false.
+// top_p 为 0 时不传给智谱.
+func (h *handler) decodeChatRequest(r *http.Request) (request zhipu.ChatCompletionRequest, stream bool, err error) {
+	var body map[string]json.RawMessage
+	if err = json.NewDecoder(io.LimitReader(r.Body, maxRequestBody)).Decode(&body); err != nil {
+		return
+	}
+	if raw, ok := body["stream"]; ok {
+		if err = json.Unmarshal(raw, &stream); err != nil {
+			return
+		}
+	}
+	body["prompt"] = body["messages"]
+	delete(body, "messages")
+	delete(body, "stream")
+	if user, ok := body["user"]; ok {
+		body["user_id"] = user
+		delete(body, "user")
+	}
+	// OpenAI 的 stop 可以是单个字符串.
+	if stop := bytes.TrimSpace(body["stop"]); len(stop) > 0 && stop[0] == '"' {
+		body["stop"] = append(append([]byte("["), stop...), ']')
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &request); err != nil {
+		return
+	}
+	if request.Model == "" || len(request.Messages) == 0 {
+		return request, stream, errors.New("model and messages are required")
+	}
+	request.Model = h.model(request.Model)
+	request.Incremental = true
+	clampSampling(&request)
+	return request, stream, nil
+}
+
+// clampSampling 将 OpenAI 的采样参数转换到智谱的取值范围.
+func clampSampling(request *zhipu.ChatCompletionRequest) {
+	if t := request.Temperature; t != nil {
+		switch {
+		case *t <= 0:
+			request.Temperature = nil
+			request.DoSample = zhipu.Bool(false)
+		case *t > 1:
+			request.Temperature = zhipu.Float32(1)
+		}
+	}
+	if p := request.TopP; p != nil && *p <= 0 {
+		request.TopP = nil
+	}
+}
+
+func (h *handler) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", nil, "Only POST is supported.")
+		return
+	}
+
+	request, stream, err := h.decodeChatRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", nil, err.Error())
+		return
+	}
+
+	if stream {
+		h.streamChatCompletion(w, r, request)
+		return
+	}
+
+	resp, err := h.client.CreateChatCompletion(r.Context(), request)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	out := chatCompletionResponse{
+		ID:      resp.ID,
+		Object:  "chat.completion",
+		Created: resp.Created,
+		Model:   resp.Model,
+		Usage:   resp.Usage,
+	}
+	for i, choice := range resp.Choices {
+		reason := "stop"
+		if len(choice.Message.ToolCalls) > 0 {
+			reason = "tool_calls"
+		}
+		out.Choices = append(out.Choices, chatChoice{Index: i, Message: choice.Message, FinishReason: reason})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// streamChatCompletion 将智谱 event:/data:/meta: 事件重新编码为 OpenAI 的 data: 数据块, 以 [DONE] 结束.
+func (h *handler) streamChatCompletion(w http.ResponseWriter, r *http.Request, request zhipu.ChatCompletionRequest) {
+	stream, err := h.client.CreateChatCompletionStream(r.Context(), request)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+	defer stream.Close()
+
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	created := time.Now().Unix()
+	for first := true; ; first = false {
+		resp, recvErr := stream.Recv()
+		if errors.Is(recvErr, io.EOF) {
+			break
+		}
+		if recvErr != nil {
+			writeEvent(w, errorResponse{Error: errorBody{Message: recvErr.Error(), Type: "api_error"}})
+			break
+		}
+
+		chunk := chatCompletionChunk{
+			ID:      resp.ID,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   request.Model,
+		}
+		var content string
+		for _, choice := range resp.Choices {
+			content += choice.Delta.Content
+		}
+		delta := zhipu.ChatCompletionStreamChoiceDelta{Content: content}
+		if first {
+			delta.Role = zhipu.ChatMessageRoleAssistant
+		}
+
+		event := resp.Event
+		if event == "error" || event == "interrupted" {
+			writeEvent(w, errorResponse{Error: errorBody{Message: content, Type: "api_error", Code: event}})
+			break
+		}
+
+		choice := chunkChoice{Delta: delta}
+		if event == "finish" {
+			reason := "stop"
+			choice.FinishReason = &reason
+			usage := zhipu.Usage{TotalTokens: resp.Meta.Usage.TotalTokens}
+			chunk.Usage = &usage
+		}
+		chunk.Choices = []chunkChoice{choice}
+		writeEvent(w, chunk)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if event == "finish" {
+			break
+		}
+	}
+
+	fmt.Fprintf(w, "data: %s\n\n", streamDone)
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+func (h *handler) embeddings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", nil, "Only POST is supported.")
+		return
+	}
+
+	var req embeddingRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestBody)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", nil, err.Error())
+		return
+	}
+	inputs, err := embeddingInputs(req.Input)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", nil, err.Error())
+		return
+	}
+
+	out := embeddingResponse{Object: "list", Model: req.Model}
+	for i, input := range inputs {
+		resp, embedErr := h.client.CreateEmbeddings(r.Context(), zhipu.EmbeddingRequest{
+			Model:  h.model(req.Model),
+			Prompt: input,
+		})
+		if embedErr != nil {
+			writeClientError(w, embedErr)
+			return
+		}
+		out.Data = append(out.Data, embeddingData{Object: "embedding", Index: i, Embedding: resp.Embedding})
+		out.Usage.PromptTokens += resp.Usage.PromptTokens
+		out.Usage.TotalTokens += resp.Usage.TotalTokens
+		if out.Model == "" {
+			out.Model = resp.Model
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func embeddingInputs(input any) ([]string, error) {
+	switch v := input.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		inputs := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, errors.New("input must be a string or an array of strings")
+			}
+			inputs = append(inputs, s)
+		}
+		if len(inputs) > 0 {
+			return inputs, nil
+		}
+	}
+	return nil, errors.New("input must be a string or an array of strings")
+}
+
+// writeClientError 将客户端错误转换为 OpenAI 错误格式.
+func writeClientError(w http.ResponseWriter, err error) {
+	status, errType := http.StatusBadGateway, "api_error"
+	var code any
+
+	var apiErr *zhipu.APIError
+	var reqErr *zhipu.RequestError
+	switch {
+	case errors.Is(err, zhipu.ErrInvalidRequest):
+		status = http.StatusBadRequest
+	case errors.Is(err, zhipu.ErrCircuitOpen):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, zhipu.ErrBudgetExceeded):
+		status, errType, code = http.StatusTooManyRequests, "insufficient_quota", "insufficient_quota"
+	case errors.As(err, &apiErr):
+		code = apiErr.Code
+		if apiErr.HTTPStatusCode >= http.StatusBadRequest {
+			status = apiErr.HTTPStatusCode
+		}
+	case errors.As(err, &reqErr):
+		if reqErr.HTTPStatusCode >= http.StatusBadRequest {
+			status = reqErr.HTTPStatusCode
+		}
+	}
+
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		errType = "authentication_error"
+	case status == http.StatusTooManyRequests && errType == "api_error":
+		errType = "rate_limit_error"
+	case status >= http.StatusBadRequest && status < http.StatusInternalServerError && errType == "api_error":
+		errType = "invalid_request_error"
+	}
+	writeError(w, status, errType, code, err.Error())
+}
+
+func writeError(w http.ResponseWriter, status int, errType string, code any, message string) {
+	writeJSON(w, status, errorResponse{Error: errorBody{Message: message, Type: errType, Code: code}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeEvent(w io.Writer, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
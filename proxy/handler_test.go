@@ -0,0 +1,165 @@
+package proxy_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+	"github.com/gtkit/go-zhipu/proxy"
+)
+
+func newProxy(t *testing.T) *httptest.Server {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/glm-4/invoke":
+			fmt.Fprint(w, `{"code":200,"success":true,"data":{"task_id":"t1",`+
+				`"choices":[{"role":"assistant","content":"hello"}],"usage":{"total_tokens":5}}}`)
+		case "/glm-4/sse-invoke":
+			fmt.Fprint(w, "event:add\nid:t1\ndata:hel\n\n")
+			fmt.Fprint(w, "event:finish\nid:t1\ndata:lo\nmeta:{\"usage\":{\"total_tokens\":5}}\n\n")
+		case "/text_embedding/invoke":
+			fmt.Fprint(w, `{"code":200,"success":true,"data":{"embedding":[0.1,0.2],"usage":{"total_tokens":2}}}`)
+		case "/bad/invoke":
+			fmt.Fprint(w, `{"code":1211,"msg":"模型不存在","success":false}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = upstream.URL + "/"
+	handler := proxy.NewHandler(zhipu.NewClientWithConfig(config),
+		proxy.WithAPIKeys("sk-proxy"),
+		proxy.WithModelAliases(map[string]string{"gpt-4": "glm-4"}))
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func post(t *testing.T, url, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer sk-proxy")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func TestChatCompletions(t *testing.T) {
+	server := newProxy(t)
+
+	resp, body := post(t, server.URL+"/v1/chat/completions", `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+	var out struct {
+		Object  string `json:"object"`
+		Choices []struct {
+			Message      zhipu.ChatCompletionMessage `json:"message"`
+			FinishReason string                      `json:"finish_reason"`
+		} `json:"choices"`
+		Usage zhipu.Usage `json:"usage"`
+	}
+	if err := json.Unmarshal([]byte(body), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Object != "chat.completion" || out.Choices[0].Message.Content != "hello" ||
+		out.Choices[0].FinishReason != "stop" || out.Usage.TotalTokens != 5 {
+		t.Fatalf("unexpected response: %s", body)
+	}
+}
+
+func TestChatCompletionsStream(t *testing.T) {
+	server := newProxy(t)
+
+	resp, body := post(t, server.URL+"/v1/chat/completions",
+		`{"model":"glm-4","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := strings.Split(strings.TrimSpace(body), "\n\n")
+	if len(events) != 3 || events[2] != "data: [DONE]" {
+		t.Fatalf("unexpected events:\n%s", body)
+	}
+	if !strings.Contains(events[0], `"object":"chat.completion.chunk"`) ||
+		!strings.Contains(events[0], `"delta":{"content":"hel","role":"assistant"}`) ||
+		!strings.Contains(events[0], `"finish_reason":null`) {
+		t.Fatalf("unexpected first chunk: %s", events[0])
+	}
+	if !strings.Contains(events[1], `"finish_reason":"stop"`) || !strings.Contains(events[1], `"total_tokens":5`) {
+		t.Fatalf("unexpected last chunk: %s", events[1])
+	}
+}
+
+func TestChatCompletionsClampsTemperature(t *testing.T) {
+	server := newProxy(t)
+
+	for _, temperature := range []string{"0", "2"} {
+		resp, body := post(t, server.URL+"/v1/chat/completions",
+			`{"model":"glm-4","temperature":`+temperature+`,"messages":[{"role":"user","content":"hi"}]}`)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("temperature %s: unexpected status %d: %s", temperature, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	server := newProxy(t)
+
+	resp, body := post(t, server.URL+"/v1/embeddings", `{"model":"text_embedding","input":["a","b"]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+	if !strings.Contains(body, `"index":1,"embedding":[0.1,0.2]`) || !strings.Contains(body, `"total_tokens":4`) {
+		t.Fatalf("unexpected response: %s", body)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server := newProxy(t)
+
+	resp, body := post(t, server.URL+"/v1/chat/completions", `{"model":"bad","messages":[{"role":"user","content":"hi"}]}`)
+	if resp.StatusCode != http.StatusBadGateway || !strings.Contains(body, `"code":1211`) || !strings.Contains(body, `"type":"api_error"`) {
+		t.Fatalf("unexpected error response %d: %s", resp.StatusCode, body)
+	}
+
+	resp, body = post(t, server.URL+"/v1/chat/completions", `{"model":"glm-4"}`)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "invalid_request_error") {
+		t.Fatalf("unexpected error response %d: %s", resp.StatusCode, body)
+	}
+
+	resp, body = post(t, server.URL+"/v1/chat/completions",
+		`{"model":"glm-4","max_tokens":-1,"messages":[{"role":"user","content":"hi"}]}`)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "max_tokens") {
+		t.Fatalf("unexpected error response %d: %s", resp.StatusCode, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader("{}"))
+	unauthorized, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unauthorized.Body.Close()
+	if unauthorized.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", unauthorized.StatusCode)
+	}
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"github.com/gtkit/go-zhipu"
+)
+
+// chatCompletionResponse OpenAI 对话补全响应.
+type chatCompletionResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   zhipu.Usage  `json:"usage"`
+}
+
+type chatChoice struct {
+	Index        int                         `json:"index"`
+	Message      zhipu.ChatCompletionMessage `json:"message"`
+	FinishReason string                      `json:"finish_reason"`
+}
+
+// chatCompletionChunk OpenAI 流式数据块.
+type chatCompletionChunk struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []chunkChoice `json:"choices"`
+	Usage   *zhipu.Usage  `json:"usage,omitempty"`
+}
+
+type chunkChoice struct {
+	Index        int                                   `json:"index"`
+	Delta        zhipu.ChatCompletionStreamChoiceDelta `json:"delta"`
+	FinishReason *string                               `json:"finish_reason"`
+}
+
+// embeddingRequest OpenAI 向量请求, input 可以是字符串或字符串数组.
+type embeddingRequest struct {
+	Model string `json:"model"`
+	Input any    `json:"input"`
+}
+
+type embeddingResponse struct {
+	Object string          `json:"object"`
+	Data   []embeddingData `json:"data"`
+	Model  string          `json:"model"`
+	Usage  embeddingUsage  `json:"usage"`
+}
+
+type embeddingData struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"`
+	Embedding []float64 `json:"embedding"`
+}
+
+type embeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// errorResponse OpenAI 错误格式.
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    any     `json:"code"`
+}
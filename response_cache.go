@@ -0,0 +1,128 @@
+package zhipu
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+type cacheBypassKey struct{}
+
+// WithCacheBypass 返回跳过响应缓存的 context, 请求既不读取也不写入缓存.
+func WithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(cacheBypassKey{}).(bool)
+	return bypass
+}
+
+// cacheBackend 参与缓存键计算的后端信息, 不包含凭证.
+type cacheBackend struct {
+	Name    string      `json:"name"`
+	Kind    BackendKind `json:"kind"`
+	BaseURL string      `json:"base_url"`
+	Model   string      `json:"model"`
+}
+
+// cacheKey 返回请求的规范哈希, 包含后端列表(及映射后的模型)、模型、消息及全部参数,
+// 配置不同后端的客户端共用一个 Store 时不会互相命中; Incremental 只影响流式返回方式, 不参与计算.
+func (c *Client) cacheKey(ctx context.Context, request ChatCompletionRequest) (string, bool) {
+	if c.config.Cache == nil || cacheBypassed(ctx) {
+		return "", false
+	}
+
+	backends := c.backends()
+	key := struct {
+		Backends []cacheBackend        `json:"backends"`
+		Request  ChatCompletionRequest `json:"request"`
+	}{Backends: make([]cacheBackend, 0, len(backends)), Request: request}
+	for _, backend := range backends {
+		key.Backends = append(key.Backends, cacheBackend{
+			Name:    backend.Name,
+			Kind:    backend.Kind,
+			BaseURL: backend.baseURL(),
+			Model:   backend.model(request.Model),
+		})
+	}
+	key.Request.Incremental = false
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return "chat-v2-" + hex.EncodeToString(sum[:]), true
+}
+
+// cachedResponse 读取缓存的响应, 存储出错视为未命中.
+func (c *Client) cachedResponse(ctx context.Context, key string) (response ChatCompletionResponse, ok bool) {
+	data, ok, err := c.config.Cache.Get(ctx, key)
+	if err != nil || !ok {
+		return response, false
+	}
+	if err = json.Unmarshal(data, &response); err != nil || len(response.Choices) == 0 {
+		return response, false
+	}
+	response.Cached = true
+	return response, true
+}
+
+// storeResponse 写入缓存, 缓存是可选的, 写入失败不影响本次请求.
+func (c *Client) storeResponse(ctx context.Context, key string, response ChatCompletionResponse) {
+	if len(response.Choices) == 0 {
+		return
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	_ = c.config.Cache.Set(ctx, key, data, c.config.CacheTTL)
+}
+
+// newCachedStream 将缓存的响应作为一次完整的流回放, 内容在一个 add 事件中返回, 随后是带用量的 finish 事件.
+func newCachedStream(response ChatCompletionResponse) (*GlmChatCompletionStream, error) {
+	var body bytes.Buffer
+	for _, chunk := range []openAIChatCompletionChunk{cachedChunk(response, false), cachedChunk(response, true)} {
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&body, "data: %s\n\n", data)
+	}
+	fmt.Fprintf(&body, "data: %s\n\n", openAIStreamDone)
+
+	stream := &GlmChatCompletionStream{
+		streamReader: newStreamReader[GlmChatCompletionStreamResponse](&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(&body),
+		}, &openAIChunkDecoder{}),
+		Backend: response.Backend,
+		Cached:  true,
+	}
+	return stream, nil
+}
+
+func cachedChunk(response ChatCompletionResponse, finish bool) (chunk openAIChatCompletionChunk) {
+	chunk.ID = response.ID
+	chunk.Created = response.Created
+	chunk.Model = response.Model
+
+	choice := response.Choices[0].Message
+	delta := ChatCompletionStreamChoiceDelta{Role: choice.Role, Content: choice.Content}
+	reason := ""
+	if finish {
+		delta = ChatCompletionStreamChoiceDelta{}
+		reason = "stop"
+		usage := response.Usage
+		chunk.Usage = &usage
+		chunk.WebSearch = response.WebSearch
+	}
+	chunk.Choices = append(chunk.Choices, openAIChunkChoice{Delta: delta, FinishReason: reason})
+	return chunk
+}
@@ -0,0 +1,190 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+)
+
+// Decoder 按 WHATWG EventSource 规范从流中解析事件, 另外支持智谱扩展的 meta 字段.
+//
+// 支持 LF、CRLF 与 CR 三种换行, 忽略以 ":" 开头的注释行, 多个 data 字段以 "\n" 连接,
+// id 字段在后续事件中保持不变直到被再次设置; retry 字段设置整个流的重连间隔, 不需要 data.
+type Decoder struct {
+	r *bufio.Reader
+
+	// Unknown 设置后遇到无法识别的字段行, 以及流末尾未以换行结束的行时调用,
+	// 例如流式接口返回的 JSON 错误体. line 仅在调用期间有效.
+	Unknown func(line []byte)
+
+	line   []byte
+	skipLF bool
+	bom    bool
+	retry  int
+
+	// 以下缓冲区在事件之间复用, Next 返回的 Event 引用它们.
+	id    []byte
+	event []byte
+	data  []byte
+	meta  []byte
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Decoder{r: br, bom: true}
+}
+
+// LastEventID 最近一次设置的事件 ID, 重连时作为 Last-Event-ID 请求头发送. 返回的切片在下次解析前有效.
+func (d *Decoder) LastEventID() []byte {
+	return d.id
+}
+
+// Retry 流中最近一次设置的重连间隔毫秒数, 0 表示未设置.
+func (d *Decoder) Retry() int {
+	return d.retry
+}
+
+// Decode 返回下一个事件. 流结束时返回 io.EOF, 未以空行结束的事件按规范丢弃.
+// Event 为空的事件按规范应视为 "message" 类型.
+func (d *Decoder) Decode() (Event, error) {
+	event, err := d.Next()
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:    clone(event.ID),
+		Event: clone(event.Event),
+		Data:  clone(event.Data),
+		Meta:  clone(event.Meta),
+		Retry: event.Retry,
+	}, nil
+}
+
+// Next 与 Decode 相同, 但返回的 Event 引用 Decoder 内部的缓冲区, 仅在下次调用 Next 或 Decode 前有效.
+// 缓冲区增长到足够大之后不再分配内存, 适合长时间的流.
+func (d *Decoder) Next() (Event, error) {
+	var (
+		event                      Event
+		hasEvent, hasData, hasMeta bool
+	)
+	d.data = d.data[:0]
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+
+		if len(line) == 0 {
+			// 空行分发事件; 没有 data 与 meta 的事件不分发.
+			if !hasData && !hasMeta {
+				event, hasEvent = Event{}, false
+				continue
+			}
+			event.ID = d.id
+			if hasEvent {
+				event.Event = d.event
+			}
+			if hasData {
+				event.Data = d.data[:len(d.data)-1]
+			}
+			if hasMeta {
+				event.Meta = d.meta
+			}
+			return event, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], line[i+1:]
+			if len(value) > 0 && value[0] == ' ' {
+				value = value[1:]
+			}
+		}
+
+		switch string(field) {
+		case "event":
+			d.event = append(d.event[:0], value...)
+			hasEvent = true
+		case "data":
+			d.data = append(append(d.data, value...), '\n')
+			hasData = true
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				d.id = append(d.id[:0], value...)
+			}
+		case "retry":
+			if retry, convErr := strconv.Atoi(string(value)); convErr == nil && retry >= 0 && isDigits(value) {
+				d.retry = retry
+				event.Retry = retry
+			}
+		case "meta":
+			d.meta = append(d.meta[:0], value...)
+			hasMeta = true
+		default:
+			if d.Unknown != nil {
+				d.Unknown(line)
+			}
+		}
+	}
+}
+
+// readLine 读取一行, 不含换行符; 返回的切片在下次调用前有效.
+func (d *Decoder) readLine() ([]byte, error) {
+	d.line = d.line[:0]
+	for {
+		buf, err := d.r.Peek(1)
+		if err != nil {
+			if len(d.line) > 0 && d.Unknown != nil {
+				d.Unknown(d.line)
+			}
+			return nil, err
+		}
+		if d.skipLF {
+			d.skipLF = false
+			if buf[0] == '\n' {
+				_, _ = d.r.Discard(1)
+				continue
+			}
+		}
+		if d.bom {
+			d.bom = false
+			if bom, _ := d.r.Peek(3); bytes.Equal(bom, []byte("\xEF\xBB\xBF")) {
+				_, _ = d.r.Discard(3)
+				continue
+			}
+		}
+
+		buf, _ = d.r.Peek(d.r.Buffered())
+		if i := bytes.IndexAny(buf, "\r\n"); i >= 0 {
+			d.line = append(d.line, buf[:i]...)
+			d.skipLF = buf[i] == '\r'
+			_, _ = d.r.Discard(i + 1)
+			return d.line, nil
+		}
+		d.line = append(d.line, buf...)
+		_, _ = d.r.Discard(len(buf))
+	}
+}
+
+func isDigits(b []byte) bool {
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return len(b) > 0
+}
+
+func clone(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
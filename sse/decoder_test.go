@@ -0,0 +1,163 @@
+package sse_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gtkit/go-zhipu/sse"
+)
+
+func decodeAll(t *testing.T, input string) []sse.Event {
+	t.Helper()
+	d := sse.NewDecoder(strings.NewReader(input))
+	var events []sse.Event
+	for {
+		event, err := d.Decode()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []sse.Event
+	}{
+		{
+			name:  "zhipu events",
+			input: "event:add\nid:1\ndata:你好\n\nevent:finish\nid:1\ndata:\nmeta:{\"usage\":{\"total_tokens\":3}}\n\n",
+			want: []sse.Event{
+				{ID: []byte("1"), Event: []byte("add"), Data: []byte("你好")},
+				{ID: []byte("1"), Event: []byte("finish"), Data: []byte{}, Meta: []byte(`{"usage":{"total_tokens":3}}`)},
+			},
+		},
+		{
+			name:  "line endings",
+			input: "data: a\r\ndata: b\r\n\r\ndata: c\rdata: d\r\r",
+			want: []sse.Event{
+				{Data: []byte("a\nb")},
+				{Data: []byte("c\nd")},
+			},
+		},
+		{
+			name:  "comments retry and id persistence",
+			input: "\xEF\xBB\xBF: keepalive\nretry: 3000\nid: 7\ndata\n\n: ping\ndata:  two spaces\n\nretry: 1x\ndata: x\n\n",
+			want: []sse.Event{
+				{ID: []byte("7"), Data: []byte{}, Retry: 3000},
+				{ID: []byte("7"), Data: []byte(" two spaces")},
+				{ID: []byte("7"), Data: []byte("x")},
+			},
+		},
+		{
+			name:  "events without data and unterminated events are dropped",
+			input: "event: empty\n\nid: 1\n\ndata: tail",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeAll(t, tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("unexpected events:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoderRetryWithoutData(t *testing.T) {
+	d := sse.NewDecoder(strings.NewReader("retry: 3000\n\ndata: x\n\nretry: 500\n\n"))
+	event, err := d.Decode()
+	if err != nil || string(event.Data) != "x" || event.Retry != 0 || d.Retry() != 3000 {
+		t.Fatalf("unexpected event %q, retry %d: %v", event, d.Retry(), err)
+	}
+	if _, err = d.Decode(); !errors.Is(err, io.EOF) || d.Retry() != 500 {
+		t.Fatalf("expected EOF with retry 500, got retry %d: %v", d.Retry(), err)
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	var out strings.Builder
+	w := sse.NewWriter(&out)
+	want := sse.Event{ID: []byte("1"), Event: []byte("add"), Data: []byte("line one\nline two"), Meta: []byte(`{}`)}
+	if err := w.WriteEvent(want); err != nil {
+		t.Fatal(err)
+	}
+
+	events := decodeAll(t, out.String())
+	if len(events) != 1 || !reflect.DeepEqual(events[0], want) {
+		t.Fatalf("round trip mismatch: %q", events)
+	}
+}
+
+func TestWriterLineBreaks(t *testing.T) {
+	var out strings.Builder
+	w := sse.NewWriter(&out)
+	if err := w.WriteEvent(sse.Event{Data: []byte("abc\rdef\r\nghi")}); err != nil {
+		t.Fatal(err)
+	}
+	if events := decodeAll(t, out.String()); len(events) != 1 || string(events[0].Data) != "abc\ndef\nghi" {
+		t.Fatalf("unexpected events %q", events)
+	}
+
+	for _, e := range []sse.Event{
+		{ID: []byte("1\ndata: injected"), Data: []byte("x")},
+		{Event: []byte("add\rretry: 1"), Data: []byte("x")},
+		{ID: []byte("1\x00"), Data: []byte("x")},
+		{Meta: []byte("{}\n\ndata: injected"), Data: []byte("x")},
+	} {
+		out.Reset()
+		if err := w.WriteEvent(e); !errors.Is(err, sse.ErrInvalidField) || out.Len() != 0 {
+			t.Fatalf("expected ErrInvalidField for %q, got %v, wrote %q", e, err, out.String())
+		}
+	}
+}
+
+// longStream 模拟长回复: 多个 add 事件, 最后一个带 meta 的 finish 事件.
+func longStream(events int) []byte {
+	var b strings.Builder
+	for i := 0; i < events; i++ {
+		b.WriteString("event:add\nid:8241957375823750000\ndata:这是一段比较长的增量内容 token\n\n")
+	}
+	b.WriteString("event:finish\nid:8241957375823750000\ndata:\nmeta:{\"usage\":{\"total_tokens\":1000}}\n\n")
+	return []byte(b.String())
+}
+
+func benchmarkDecoder(b *testing.B, next func(*sse.Decoder) (sse.Event, error)) {
+	stream := longStream(1000)
+	r := bytes.NewReader(stream)
+	d := sse.NewDecoder(bufio.NewReader(r))
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.Reset(stream)
+		for {
+			if _, err := next(d); err != nil {
+				if !errors.Is(err, io.EOF) {
+					b.Fatal(err)
+				}
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkDecoderNext(b *testing.B) {
+	benchmarkDecoder(b, (*sse.Decoder).Next)
+}
+
+func BenchmarkDecoderDecode(b *testing.B) {
+	benchmarkDecoder(b, (*sse.Decoder).Decode)
+}
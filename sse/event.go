@@ -0,0 +1,12 @@
+// Package sse 实现 Server-Sent Events 的编码与解析, 支持智谱扩展的 meta 字段.
+package sse
+
+// Event 一个 SSE 事件, Meta 为智谱扩展字段.
+type Event struct {
+	ID    []byte
+	Event []byte
+	Data  []byte
+	Meta  []byte
+	// Retry 该事件中设置的重连间隔毫秒数, 0 表示未设置; 不带 data 的 retry 由 Decoder.Retry 返回.
+	Retry int
+}
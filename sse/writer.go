@@ -0,0 +1,93 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// ErrInvalidField 单行字段(id、event、meta)中含有换行或 NUL, 写入后会被解析为其他字段.
+var ErrInvalidField = errors.New("sse: field contains line break or NUL")
+
+// Writer 将事件编码为 SSE 格式, 底层实现 http.Flusher 时每个事件写完后立即刷新.
+type Writer struct {
+	w       *bufio.Writer
+	flusher http.Flusher
+}
+
+func NewWriter(w io.Writer) *Writer {
+	flusher, _ := w.(http.Flusher)
+	return &Writer{w: bufio.NewWriter(w), flusher: flusher}
+}
+
+// WriteEvent 写入一个事件, 多行数据(LF、CRLF 或 CR 换行)拆分为多个 data 字段.
+// id、event 与 meta 中含有换行或 NUL 时返回 ErrInvalidField, 不写入任何内容.
+func (w *Writer) WriteEvent(e Event) error {
+	for _, field := range []struct {
+		name  string
+		value []byte
+	}{{"id", e.ID}, {"event", e.Event}, {"meta", e.Meta}} {
+		if bytes.ContainsAny(field.value, "\r\n\x00") {
+			return fmt.Errorf("%w: %s", ErrInvalidField, field.name)
+		}
+	}
+
+	if len(e.ID) > 0 {
+		w.writeField("id", e.ID)
+	}
+	if len(e.Event) > 0 {
+		w.writeField("event", e.Event)
+	}
+	if e.Retry > 0 {
+		w.writeField("retry", strconv.AppendInt(nil, int64(e.Retry), 10))
+	}
+
+	data := bytes.ReplaceAll(e.Data, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+	for {
+		line, rest, found := bytes.Cut(data, []byte("\n"))
+		w.writeField("data", line)
+		if !found {
+			break
+		}
+		data = rest
+	}
+
+	if len(e.Meta) > 0 {
+		w.writeField("meta", e.Meta)
+	}
+	w.w.WriteByte('\n')
+	return w.Flush()
+}
+
+// WriteComment 写入注释行, 常用作保持连接的心跳.
+func (w *Writer) WriteComment(text string) error {
+	w.w.WriteString(": ")
+	w.w.WriteString(text)
+	w.w.WriteString("\n\n")
+	return w.Flush()
+}
+
+func (w *Writer) Flush() error {
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	return nil
+}
+
+func (w *Writer) writeField(name string, value []byte) {
+	w.w.WriteString(name)
+	w.w.WriteByte(':')
+	if len(value) > 0 {
+		w.w.WriteByte(' ')
+		w.w.Write(value)
+	}
+	w.w.WriteByte('\n')
+}
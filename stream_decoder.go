@@ -0,0 +1,73 @@
+package zhipu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/gtkit/go-zhipu/sse"
+)
+
+// StreamDecoder 将流中的一个 SSE 事件解码为 T, 不同接口的流各自实现.
+// done 为 true 表示 response 是最后一个事件; 返回 io.EOF 表示流已结束且该事件没有数据.
+// event 的字段引用读取缓冲区, 只在调用期间有效, 需要保留时应复制.
+type StreamDecoder[T any] interface {
+	Decode(event sse.Event) (response T, done bool, err error)
+}
+
+// glmStreamDecoder 解码智谱 v3 的 event/id/data/meta 事件, finish 事件结束流.
+type glmStreamDecoder struct {
+	// id 与 event 缓存上一事件的字符串, 内容不变时复用以免每个事件都分配.
+	id    string
+	event string
+}
+
+// Decode meta 无法解析时仍返回事件内容, 同时返回错误.
+func (d *glmStreamDecoder) Decode(event sse.Event) (GlmChatCompletionStreamResponse, bool, error) {
+	var meta GlmMeta
+	var err error
+	if len(event.Meta) > 0 {
+		meta, err = parseMeta(event.Meta)
+	}
+
+	return GlmChatCompletionStreamResponse{
+		ID:    intern(&d.id, event.ID),
+		Event: intern(&d.event, event.Event),
+		Choices: []ChatCompletionStreamChoice{
+			{
+				Delta: ChatCompletionStreamChoiceDelta{
+					Content: string(event.Data),
+				},
+			},
+		},
+		Meta:      meta,
+		WebSearch: meta.WebSearch,
+	}, string(event.Event) == "finish", err
+}
+
+func parseMeta(data []byte) (meta GlmMeta, err error) {
+	if err = json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("invalid stream meta: %w", err)
+	}
+	return meta, nil
+}
+
+// intern 在 b 与 cached 相同时直接返回 cached, 否则更新 cached.
+func intern(cached *string, b []byte) string {
+	if string(b) != *cached {
+		*cached = string(b)
+	}
+	return *cached
+}
+
+// JSONStreamDecoder 将每个事件的 data 作为 JSON 解码为 T, data 为 [DONE] 时结束流,
+// 适用于智谱 v4 等 OpenAI 风格的流式接口.
+type JSONStreamDecoder[T any] struct{}
+
+func (JSONStreamDecoder[T]) Decode(event sse.Event) (response T, done bool, err error) {
+	if string(event.Data) == openAIStreamDone {
+		return response, true, io.EOF
+	}
+	err = json.Unmarshal(event.Data, &response)
+	return response, false, err
+}
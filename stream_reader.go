@@ -1,62 +1,45 @@
 package zhipu
 
 import (
-	"bufio"
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"sync"
 
+	"github.com/gtkit/go-zhipu/sse"
 	"github.com/gtkit/go-zhipu/utils"
 )
 
-var (
-	// errorPrefix = []byte(`data: {"error":`).
-	headerID    = []byte("id:")
-	headerData  = []byte("data:")
-	headerEvent = []byte("event:")
-	headerMeta  = []byte("meta:")
-)
-
-type StreamReaderInterface[T streamable] interface {
+type StreamReaderInterface[T any] interface {
 	Recv() (response T, err error)
 	Close()
 	processLines() (T, error)
-	unmarshalError() (errResp *ErrorResponse)
-}
-
-type streamable interface {
-	GlmChatCompletionStreamResponse
+	unmarshalError() *APIError
 }
 
-type streamReader[T streamable] struct {
+// streamReader 读取 SSE 流, 由 decoder 将每个事件解码为 T.
+type streamReader[T any] struct {
 	isFinished bool
 
-	reader         *bufio.Reader
-	response       *http.Response
+	events   *sse.Decoder
+	decoder  StreamDecoder[T]
+	response *http.Response
+	// errAccumulator 收集流中无法解析的行, 用于识别错误响应与诊断.
 	errAccumulator utils.ErrorAccumulator
-	unmarshaler    utils.Unmarshaler
+
+	deadline *streamDeadline
 }
 
-var _ StreamReaderInterface[GlmChatCompletionStreamResponse] = (*streamReader[GlmChatCompletionStreamResponse])(nil)
+// maxErrorBodySize 读取错误响应体与收集无法解析的行的上限.
+const maxErrorBodySize = 1 << 20
 
-type Event struct {
-	ID    []byte
-	Data  []byte
-	Event []byte
-	Meta  []byte
-}
+var _ StreamReaderInterface[GlmChatCompletionStreamResponse] = (*streamReader[GlmChatCompletionStreamResponse])(nil)
 
-var pool = sync.Pool{
-	New: func() interface{} {
-		return &Event{}
-	},
-}
+// Event SSE 事件.
+//
+// Deprecated: 使用 sse.Event.
+type Event = sse.Event
 
 func (stream *streamReader[T]) Recv() (response T, err error) {
 	if stream.isFinished {
@@ -68,145 +51,59 @@ func (stream *streamReader[T]) Recv() (response T, err error) {
 	return
 }
 
+// processLines 读取下一个事件并由 decoder 解码为 T.
+// 事件的缓冲区由 events 复用, 稳定状态下读取本身不分配内存.
 func (stream *streamReader[T]) processLines() (T, error) {
-	var (
-		hasErrorPrefix bool
-		response       T
-	)
-
-	event, ok := pool.Get().(*Event)
-	if !ok {
-		return *new(T), nil
+	if err := stream.deadline.arm(stream.response.Body); err != nil {
+		stream.isFinished = true
+		return *new(T), err
 	}
-
-	for {
-		rawLine, readErr := stream.reader.ReadBytes('\n')
-
-		if readErr != nil || hasErrorPrefix {
-			respErr := stream.unmarshalError()
-			if respErr != nil {
-				return *new(T), fmt.Errorf("error, %w", respErr.Error)
-			}
-			if errors.Is(readErr, context.Canceled) {
-				return *new(T), readErr
-			}
-			return *new(T), fmt.Errorf("stream read error, %w", readErr)
-		}
-
-		if bytes.Equal(rawLine, []byte("\n")) {
-			meta := &GlmMeta{}
-			if len(event.Meta) > 0 {
-				if err := json.Unmarshal(event.Meta, meta); err != nil {
-					log.Println("---Meta Unmarshal error:", err)
-				}
-			}
-
-			response = T{
-				ID:    string(event.ID),
-				Event: string(event.Event),
-				Choices: []ChatCompletionStreamChoice{
-					{
-						Delta: ChatCompletionStreamChoiceDelta{
-							Content: string(event.Data),
-						},
-					},
-				},
-				Meta: *meta,
-			}
-
-			putEvent(event)
-
-			return response, nil
-		}
-
-		e, _ := processEvent(rawLine)
-
-		if e.Event != nil {
-			if bytes.Equal(e.Event, []byte("finish")) {
-				stream.isFinished = true
-			}
-			event.Event = e.Event
-		}
-		if e.ID != nil {
-			event.ID = e.ID
-		}
-		if e.Data != nil {
-			event.Data = append(event.Data, e.Data...)
+	event, err := stream.events.Next()
+	if timeoutErr := stream.deadline.disarm(err); timeoutErr != nil {
+		stream.isFinished = true
+		return *new(T), timeoutErr
+	}
+	if err != nil {
+		if apiErr := stream.unmarshalError(); apiErr != nil {
+			return *new(T), fmt.Errorf("error, %w", apiErr)
 		}
-		if e.Meta != nil {
-			event.Meta = e.Meta
+		if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
+			return *new(T), err
 		}
+		return *new(T), fmt.Errorf("stream read error, %w", err)
 	}
-}
 
-func putEvent(e *Event) {
-	e.ID = nil
-	e.Event = nil
-	e.Data = nil
-	pool.Put(e)
+	response, done, err := stream.decoder.Decode(event)
+	if done || errors.Is(err, io.EOF) {
+		stream.isFinished = true
+	}
+	return response, err
 }
 
-func (stream *streamReader[T]) unmarshalError() (errResp *ErrorResponse) {
+// unmarshalError 将收集到的无法解析的行作为错误响应体解析.
+func (stream *streamReader[T]) unmarshalError() *APIError {
 	errBytes := stream.errAccumulator.Bytes()
 	if len(errBytes) == 0 {
-		return
+		return nil
 	}
+	return decodeErrorBody(stream.response.StatusCode, errBytes)
+}
 
-	err := stream.unmarshaler.Unmarshal(errBytes, &errResp)
-	if err != nil {
-		errResp = nil
+// captureLine 记录无法解析的行, 超过 maxErrorBodySize 后丢弃.
+func (stream *streamReader[T]) captureLine(line []byte) {
+	if len(stream.errAccumulator.Bytes())+len(line) >= maxErrorBodySize {
+		return
 	}
+	_ = stream.errAccumulator.Write(line)
+	_ = stream.errAccumulator.Write([]byte{'\n'})
+}
 
-	return
+// Unparsed 返回流中无法解析的行, 以换行分隔, 用于诊断上游返回的异常内容.
+func (stream *streamReader[T]) Unparsed() []byte {
+	return stream.errAccumulator.Bytes()
 }
 
 func (stream *streamReader[T]) Close() {
+	stream.deadline.stop()
 	stream.response.Body.Close()
 }
-
-func processEvent(msg []byte) (event *Event, err error) {
-	var e Event
-
-	if len(msg) < 1 {
-		return nil, errors.New("event message was empty")
-	}
-
-	switch {
-	case bytes.HasPrefix(msg, headerID):
-		e.ID = append([]byte(nil), trimHeader(len(headerID), msg)...)
-	case bytes.HasPrefix(msg, headerData):
-		e.Data = append(e.Data, trimHeader(len(headerData), msg)...)
-		if bytes.Equal(msg, []byte("data:   \n")) {
-			e.Data = append(e.Data, byte('\n'))
-		}
-	// The spec says that a line that simply contains the string "data" should be treated
-	// as a data field with an empty body.
-	case bytes.Equal(msg, bytes.TrimSuffix(headerData, []byte(":"))):
-		e.Data = append(e.Data, byte('\n'))
-	case bytes.HasPrefix(msg, headerEvent):
-		e.Event = append([]byte(nil), trimHeader(len(headerEvent), msg)...)
-	case bytes.HasPrefix(msg, headerMeta):
-		e.Meta = append([]byte(nil), trimHeader(len(headerMeta), msg)...)
-	default:
-		// Ignore any garbage that doesn't match what we're looking for.
-	}
-
-	// Trim the last "\n" per the spec.
-	if len(e.Data) > 1 {
-		e.Data = bytes.TrimSuffix(e.Data, []byte("\n"))
-	}
-
-	return &e, err
-}
-
-func trimHeader(size int, data []byte) []byte {
-	if data == nil || len(data) < size {
-		return data
-	}
-	data = data[size:]
-	// Remove optional leading whitespace
-	if len(data) > 0 && data[0] == 32 {
-		data = data[1:]
-	}
-	return data
-}
@@ -0,0 +1,185 @@
+package zhipu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gtkit/go-zhipu/sse"
+)
+
+const defaultRelayKeepAlive = 15 * time.Second
+
+// RelayOptions 转发流时使用的事件名与格式, 为空的字段使用默认值.
+type RelayOptions struct {
+	// DeltaEvent 增量内容的事件名, 默认为 "delta".
+	DeltaEvent string
+	// UsageEvent 结束时携带 GlmMeta 的事件名, 默认为 "usage".
+	UsageEvent string
+	// ErrorEvent 出错时的事件名, 默认为 "error".
+	ErrorEvent string
+	// DoneEvent 正常结束时的事件名, 默认为 "done", 数据为 [DONE].
+	DoneEvent string
+	// Raw 为 true 时增量事件的数据为纯文本内容, 否则为 {"id":...,"content":...} JSON.
+	Raw bool
+	// KeepAlive 心跳注释的间隔, 默认为 15 秒, 小于 0 时不发送.
+	KeepAlive time.Duration
+}
+
+func (o *RelayOptions) withDefaults() RelayOptions {
+	opts := *o
+	if opts.DeltaEvent == "" {
+		opts.DeltaEvent = "delta"
+	}
+	if opts.UsageEvent == "" {
+		opts.UsageEvent = "usage"
+	}
+	if opts.ErrorEvent == "" {
+		opts.ErrorEvent = "error"
+	}
+	if opts.DoneEvent == "" {
+		opts.DoneEvent = "done"
+	}
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = defaultRelayKeepAlive
+	}
+	return opts
+}
+
+type relayDelta struct {
+	ID      string `json:"id,omitempty"`
+	Content string `json:"content"`
+}
+
+type relayError struct {
+	Message string `json:"message"`
+}
+
+type recvResult struct {
+	response GlmChatCompletionStreamResponse
+	err      error
+}
+
+// RelayStream 将流以 SSE 格式转发给浏览器, 每个事件写完后立即刷新.
+// ctx 结束(通常是客户端断开)时停止转发并返回 ctx.Err(); 无论如何返回, 上游流都会被关闭.
+func RelayStream(ctx context.Context, w http.ResponseWriter, stream *GlmChatCompletionStream, options RelayOptions) error {
+	defer stream.Close()
+	opts := options.withDefaults()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	out := sse.NewWriter(w)
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	results := make(chan recvResult)
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		for {
+			resp, err := stream.Recv()
+			select {
+			case results <- recvResult{response: resp, err: err}:
+			case <-quit:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var keepAlive <-chan time.Time
+	if opts.KeepAlive > 0 {
+		ticker := time.NewTicker(opts.KeepAlive)
+		defer ticker.Stop()
+		keepAlive = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-keepAlive:
+			if err := out.WriteComment("keepalive"); err != nil {
+				return err
+			}
+		case res := <-results:
+			done, err := relayEvent(out, opts, res)
+			if done || err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// relayEvent 写出一次 Recv 的结果, 返回流是否已经结束.
+func relayEvent(out *sse.Writer, opts RelayOptions, res recvResult) (bool, error) {
+	if errors.Is(res.err, io.EOF) {
+		return true, writeRelayEvent(out, opts.DoneEvent, []byte("[DONE]"))
+	}
+	if res.err != nil {
+		data, _ := json.Marshal(relayError{Message: res.err.Error()})
+		if err := writeRelayEvent(out, opts.ErrorEvent, data); err != nil {
+			return true, err
+		}
+		return true, res.err
+	}
+
+	resp := res.response
+	var content strings.Builder
+	for _, choice := range resp.Choices {
+		content.WriteString(choice.Delta.Content)
+	}
+
+	if content.Len() > 0 {
+		data := []byte(content.String())
+		if !opts.Raw {
+			data, _ = json.Marshal(relayDelta{ID: resp.ID, Content: content.String()})
+		}
+		if err := writeRelayEvent(out, opts.DeltaEvent, data); err != nil {
+			return true, err
+		}
+	}
+
+	if resp.Event != "finish" {
+		return false, nil
+	}
+	meta, err := json.Marshal(resp.Meta)
+	if err != nil {
+		return true, err
+	}
+	if err = writeRelayEvent(out, opts.UsageEvent, meta); err != nil {
+		return true, err
+	}
+	return true, writeRelayEvent(out, opts.DoneEvent, []byte("[DONE]"))
+}
+
+func writeRelayEvent(out *sse.Writer, event string, data []byte) error {
+	return out.WriteEvent(sse.Event{Event: []byte(event), Data: data})
+}
+
+// RelayHandler 返回一个 http.Handler, 使用 open 为每个请求创建流并以 SSE 格式转发.
+// open 出错时返回 502 与错误信息.
+func RelayHandler(
+	open func(r *http.Request) (*GlmChatCompletionStream, error),
+	options RelayOptions,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream, err := open(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		_ = RelayStream(r.Context(), w, stream, options)
+	})
+}
@@ -0,0 +1,195 @@
+package zhipu
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrFirstTokenTimeout = errors.New("stream timeout: no event received before first event timeout")
+	ErrStreamIdleTimeout = errors.New("stream timeout: no event received within idle timeout")
+	ErrStreamTimeout     = errors.New("stream timeout: total stream duration exceeded")
+)
+
+// StreamTimeouts 流式调用的超时设置, 为 0 的项不限制. 超时时关闭连接, Recv 返回对应的错误.
+// 与 HTTPClient.Timeout 不同, 只要事件持续到达, 长时间的流不会被中断.
+type StreamTimeouts struct {
+	// FirstEvent 从发送请求到第一个事件的最长等待时间, 包括等待响应头.
+	FirstEvent time.Duration
+	// Idle 两个事件之间的最长间隔.
+	Idle time.Duration
+	// Total 从发送请求开始整个流的最长持续时间.
+	Total time.Duration
+}
+
+// streamDeadline 在每次读取事件前设置定时器, 超时时关闭响应体以唤醒阻塞的读取.
+// arm 与 disarm 只在调用 Recv 的 goroutine 中调用, stop 可以在其他 goroutine 中与之并发调用.
+type streamDeadline struct {
+	timeouts StreamTimeouts
+	start    time.Time
+	// received 已收到事件, 只在调用 Recv 的 goroutine 中读写.
+	received bool
+
+	mu    sync.Mutex
+	timer *time.Timer
+	body  io.Closer
+	// armed 与 deadline 为当前读取的超时时间, 过期的定时器回调据此忽略.
+	armed    bool
+	deadline time.Time
+	// reason 当前定时器对应的超时错误.
+	reason error
+	// closed 响应体因超时被关闭时的错误, 之后的读取都返回该错误.
+	closed  error
+	stopped bool
+	// cancel 取消等待响应头的请求 context, 流关闭时调用.
+	cancel context.CancelFunc
+}
+
+// newStreamDeadline 在发送请求前创建, FirstEvent 与 Total 从此时开始计算.
+func newStreamDeadline(timeouts StreamTimeouts) *streamDeadline {
+	if timeouts == (StreamTimeouts{}) {
+		return nil
+	}
+	return &streamDeadline{timeouts: timeouts, start: time.Now()}
+}
+
+// next 返回距离下一个超时的时间与对应的错误, 不限制时错误为 nil.
+func (d *streamDeadline) next() (wait time.Duration, reason error) {
+	if d.received {
+		if d.timeouts.Idle > 0 {
+			wait, reason = d.timeouts.Idle, ErrStreamIdleTimeout
+		}
+	} else if d.timeouts.FirstEvent > 0 {
+		wait, reason = time.Until(d.start.Add(d.timeouts.FirstEvent)), ErrFirstTokenTimeout
+	}
+	if d.timeouts.Total > 0 {
+		if total := time.Until(d.start.Add(d.timeouts.Total)); reason == nil || total < wait {
+			wait, reason = total, ErrStreamTimeout
+		}
+	}
+	return wait, reason
+}
+
+// bindRequest 使等待响应头同样受 FirstEvent 与 Total 限制, 超时时取消请求.
+func (d *streamDeadline) bindRequest(req *http.Request) *http.Request {
+	if d == nil {
+		return req
+	}
+	wait, reason := d.next()
+	if reason == nil {
+		return req
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cancel, d.reason = cancel, reason
+	d.timer = time.AfterFunc(wait, cancel)
+	return req.WithContext(ctx)
+}
+
+// receivedHeaders 在请求返回后调用, 等待响应头超时时返回对应的错误.
+func (d *streamDeadline) receivedHeaders() error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer == nil {
+		return nil
+	}
+	fired := !d.timer.Stop()
+	// 之后读取事件的定时器关闭响应体, 与此处的定时器分开.
+	d.timer = nil
+	if fired {
+		return d.reason
+	}
+	return nil
+}
+
+// arm 在读取下一个事件前调用, 超时已到或响应体已因超时关闭时直接返回错误.
+func (d *streamDeadline) arm(body io.Closer) error {
+	if d == nil {
+		return nil
+	}
+	wait, reason := d.next()
+	if reason == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed != nil {
+		return d.closed
+	}
+	if d.stopped {
+		return nil
+	}
+	if wait <= 0 {
+		d.closed = reason
+		body.Close()
+		return reason
+	}
+	d.body, d.reason = body, reason
+	d.armed, d.deadline = true, time.Now().Add(wait)
+	if d.timer == nil {
+		d.timer = time.AfterFunc(wait, d.expire)
+	} else {
+		d.timer.Reset(wait)
+	}
+	return nil
+}
+
+// expire 定时器回调, 忽略读取已返回或已重新设置后的过期回调.
+func (d *streamDeadline) expire() {
+	d.mu.Lock()
+	if !d.armed || d.stopped || time.Now().Before(d.deadline) {
+		d.mu.Unlock()
+		return
+	}
+	d.armed = false
+	d.closed = d.reason
+	body := d.body
+	d.mu.Unlock()
+	body.Close()
+}
+
+// disarm 在读取返回后调用, 读取因定时器关闭响应体而失败时返回对应的超时错误.
+func (d *streamDeadline) disarm(readErr error) error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.armed = false
+	closed := d.closed
+	d.mu.Unlock()
+
+	if readErr != nil {
+		return closed
+	}
+	d.received = true
+	return nil
+}
+
+// stop 停止定时器并取消请求, 可以与 Recv 并发调用.
+func (d *streamDeadline) stop() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.stopped, d.armed = true, false
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	cancel := d.cancel
+	d.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
@@ -0,0 +1,157 @@
+package zhipu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gtkit/go-zhipu/jsonschema"
+)
+
+type ChatCompletionResponseFormatType string
+
+const (
+	ChatCompletionResponseFormatTypeJSONObject ChatCompletionResponseFormatType = "json_object"
+	ChatCompletionResponseFormatTypeText       ChatCompletionResponseFormatType = "text"
+)
+
+// ChatCompletionResponseFormat 输出格式.
+type ChatCompletionResponseFormat struct {
+	Type ChatCompletionResponseFormatType `json:"type"`
+}
+
+var ErrNoChoices = errors.New("response contains no choices")
+
+// StructuredOutputError 模型输出无法解析或不符合 Schema, Content 为最后一次的原始输出.
+type StructuredOutputError struct {
+	Content  string
+	Attempts int
+	Err      error
+}
+
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("structured output invalid after %d attempt(s), %v", e.Attempts, e.Err)
+}
+
+func (e *StructuredOutputError) Unwrap() error {
+	return e.Err
+}
+
+type structuredOptions struct {
+	maxAttempts int
+}
+
+type StructuredOption func(*structuredOptions)
+
+// WithMaxAttempts 设置最多请求次数, 输出无效时会把校验错误发回模型要求修正, 默认只请求一次.
+func WithMaxAttempts(n int) StructuredOption {
+	return func(o *structuredOptions) {
+		if n > 0 {
+			o.maxAttempts = n
+		}
+	}
+}
+
+const structuredInstruction = "\n\n请仅输出符合以下 JSON Schema 的 JSON 数据, 不要输出任何其他内容:\n"
+
+// CreateStructured 请求模型输出 JSON 并解码为 T.
+// 由 T 生成的 JSON Schema 会附加到最后一条 user 消息中, 同时设置 response_format 为 json_object;
+// 输出中的 markdown 代码块标记会被去除, 并在解码前按 Schema 校验必填字段与类型.
+func CreateStructured[T any](
+	ctx context.Context,
+	client ChatCompletion[ChatCompletionRequest],
+	request ChatCompletionRequest,
+	opts ...StructuredOption,
+) (result T, err error) {
+	options := structuredOptions{maxAttempts: 1}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	schema, err := jsonschema.GenerateSchemaForType(result)
+	if err != nil {
+		return
+	}
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return
+	}
+
+	request.Messages = withSchemaInstruction(request.Messages, string(schemaJSON))
+	if request.ResponseFormat == nil {
+		request.ResponseFormat = &ChatCompletionResponseFormat{Type: ChatCompletionResponseFormatTypeJSONObject}
+	}
+
+	var content string
+	for attempt := 1; attempt <= options.maxAttempts; attempt++ {
+		var resp ChatCompletionResponse
+		resp, err = client.CreateChatCompletion(ctx, request)
+		if err != nil {
+			return
+		}
+		if len(resp.Choices) == 0 {
+			err = ErrNoChoices
+			return
+		}
+
+		content = resp.Choices[0].Message.Content
+		raw := stripCodeFence(content)
+		if err = schema.Validate([]byte(raw)); err == nil {
+			if err = json.Unmarshal([]byte(raw), &result); err == nil {
+				return result, nil
+			}
+		}
+
+		request.Messages = append(request.Messages,
+			ChatCompletionMessage{Role: ChatMessageRoleAssistant, Content: content},
+			ChatCompletionMessage{
+				Role:    ChatMessageRoleUser,
+				Content: fmt.Sprintf("上面的输出无效: %v. 请修正后仅输出符合 Schema 的 JSON.", err),
+			},
+		)
+	}
+
+	return result, &StructuredOutputError{Content: content, Attempts: options.maxAttempts, Err: err}
+}
+
+// withSchemaInstruction 返回在最后一条 user 消息后附加 Schema 说明的消息副本.
+func withSchemaInstruction(messages []ChatCompletionMessage, schema string) []ChatCompletionMessage {
+	out := make([]ChatCompletionMessage, len(messages))
+	copy(out, messages)
+
+	instruction := structuredInstruction + schema
+	for i := len(out) - 1; i >= 0; i-- {
+		if out[i].Role != ChatMessageRoleUser {
+			continue
+		}
+		if len(out[i].MultiContent) > 0 {
+			parts := make([]ChatMessagePart, 0, len(out[i].MultiContent)+1)
+			out[i].MultiContent = append(append(parts, out[i].MultiContent...), NewTextPart(instruction))
+		} else {
+			out[i].Content += instruction
+		}
+		return out
+	}
+
+	return append(out, ChatCompletionMessage{Role: ChatMessageRoleUser, Content: strings.TrimSpace(instruction)})
+}
+
+// stripCodeFence 去除 ```json ... ``` 代码块标记以及 JSON 前后的多余文本.
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = s[i+1:]
+		}
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	}
+
+	start := strings.IndexAny(s, "{[")
+	end := strings.LastIndexAny(s, "}]")
+	if start >= 0 && end > start {
+		s = s[start : end+1]
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,83 @@
+package test_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+type weatherArgs struct {
+	City string `json:"city" description:"城市"`
+}
+
+func TestAgentRunsTools(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req zhipu.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		calls++
+		switch calls {
+		case 1:
+			if len(req.Tools) != 2 || req.Tools[0].Function.Name != "weather" {
+				t.Errorf("unexpected tools: %+v", req.Tools)
+			}
+			fmt.Fprint(w, `{"success":true,"data":{"choices":[{"role":"assistant","content":"","tool_calls":[`+
+				`{"id":"a","type":"function","function":{"name":"weather","arguments":"{\"city\":\"北京\"}"}},`+
+				`{"id":"b","type":"function","function":{"name":"slow","arguments":"{}"}}]}]}}`)
+		default:
+			last := req.Messages[len(req.Messages)-2:]
+			if last[0].ToolCallID != "a" || last[0].Content != "北京: 晴" {
+				t.Errorf("unexpected tool result: %+v", last[0])
+			}
+			if last[1].ToolCallID != "b" || last[1].Content == "" || last[1].Role != zhipu.ChatMessageRoleTool {
+				t.Errorf("unexpected tool result: %+v", last[1])
+			}
+			fmt.Fprint(w, `{"success":true,"data":{"choices":[{"role":"assistant","content":"北京今天晴"}]}}`)
+		}
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	c := zhipu.NewClientWithConfig(config)
+
+	registry := zhipu.NewToolRegistry()
+	err := zhipu.RegisterTool(registry, "weather", "查询天气", func(_ context.Context, args weatherArgs) (string, error) {
+		return args.City + ": 晴", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = zhipu.RegisterTool(registry, "slow", "超时工具", func(ctx context.Context, _ struct{}) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}, zhipu.WithToolTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	agent := zhipu.NewAgent(c, registry, zhipu.Turbo)
+	var steps int
+	agent.OnStep = func(zhipu.AgentStep) { steps++ }
+
+	result, err := agent.RunAgent(context.Background(), []zhipu.ChatCompletionMessage{
+		{Role: zhipu.ChatMessageRoleUser, Content: "北京天气怎么样"},
+	})
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	if result.Message.Content != "北京今天晴" || steps != 2 || len(result.Messages) != 5 {
+		t.Fatalf("unexpected result: %+v, steps %d", result, steps)
+	}
+	if result.Steps[0].ToolResults[1].Err == nil {
+		t.Fatalf("expected slow tool to time out")
+	}
+}
@@ -0,0 +1,192 @@
+package test_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+func TestBackendFallback(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"error":{"code":"1234","message":"网络错误"}}`)
+	}))
+	defer primary.Close()
+
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if body["model"] != "gpt-4o-mini" || body["messages"] == nil || body["prompt"] != nil {
+			t.Errorf("request not translated: %v", body)
+		}
+
+		if body["stream"] == true {
+			fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"he\"}}]}\n\n")
+			fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"delta\":{\"content\":\"llo\"},\"finish_reason\":\"stop\"}],"+
+				"\"usage\":{\"total_tokens\":7}}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		fmt.Fprint(w, `{"id":"c1","object":"chat.completion","created":1,"model":"gpt-4o-mini",`+
+			`"choices":[{"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}],`+
+			`"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}`)
+	}))
+	defer fallback.Close()
+
+	var failed []string
+	config := zhipu.DefaultConfig("token")
+	config.Backends = []zhipu.Backend{
+		{Name: "zhipu", Kind: zhipu.BackendZhipuV3, BaseURL: primary.URL + "/"},
+		{
+			Name:      "openai",
+			Kind:      zhipu.BackendOpenAI,
+			BaseURL:   fallback.URL + "/",
+			AuthToken: "sk-test",
+			Models:    map[string]string{zhipu.Turbo: "gpt-4o-mini"},
+		},
+	}
+	config.OnBackendError = func(backend string, _ error) { failed = append(failed, backend) }
+	c := zhipu.NewClientWithConfig(config)
+
+	req := zhipu.ChatCompletionRequest{
+		Model:    zhipu.Turbo,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	}
+
+	resp, err := c.CreateChatCompletion(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CreateChatCompletion: %v", err)
+	}
+	if resp.Backend != "openai" || resp.Choices[0].Message.Content != "hello" || resp.Usage.CompletionTokens != 4 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	stream, err := c.CreateChatCompletionStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CreateChatCompletionStream: %v", err)
+	}
+	defer stream.Close()
+	if stream.Backend != "openai" {
+		t.Fatalf("unexpected stream backend %q", stream.Backend)
+	}
+
+	var content string
+	var last zhipu.GlmChatCompletionStreamResponse
+	for {
+		chunk, recvErr := stream.Recv()
+		if errors.Is(recvErr, io.EOF) {
+			break
+		}
+		if recvErr != nil {
+			t.Fatalf("Recv: %v", recvErr)
+		}
+		content += chunk.Choices[0].Delta.Content
+		last = chunk
+	}
+	if content != "hello" || last.Event != "finish" || last.Meta.Usage.TotalTokens != 7 {
+		t.Fatalf("unexpected stream result %q, %+v", content, last)
+	}
+	if len(failed) != 2 || failed[0] != "zhipu" {
+		t.Fatalf("unexpected backend errors: %v", failed)
+	}
+}
+
+func TestOpenAIBackendNoChoices(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"id":"c1","object":"chat.completion","created":1,"model":"glm-4","choices":[]}`)
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.Backends = []zhipu.Backend{{Name: "v4", Kind: zhipu.BackendZhipuV4, BaseURL: server.URL + "/"}}
+	c := zhipu.NewClientWithConfig(config)
+
+	_, err := c.CreateChatCompletion(context.Background(), zhipu.ChatCompletionRequest{
+		Model:    zhipu.GLM4,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	})
+	if !errors.Is(err, zhipu.ErrNoChoices) {
+		t.Fatalf("expected ErrNoChoices, got %v", err)
+	}
+}
+
+func TestOpenAIStreamUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			StreamOptions *struct {
+				IncludeUsage bool `json:"include_usage"`
+			} `json:"stream_options"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.StreamOptions == nil || !body.StreamOptions.IncludeUsage {
+			t.Errorf("expected stream_options.include_usage, got %+v %v", body.StreamOptions, err)
+		}
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"he\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"delta\":{\"content\":\"llo\"},\"finish_reason\":\"stop\"}]}\n\n")
+		if r.URL.Path != "/none/chat/completions" {
+			fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":4,\"total_tokens\":7}}\n\n")
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	for _, tt := range []struct {
+		path  string
+		usage int
+	}{{path: "/", usage: 7}, {path: "/none/", usage: 0}} {
+		tracker := zhipu.NewUsageTracker(nil)
+		config := zhipu.DefaultConfig("token")
+		config.Backends = []zhipu.Backend{{
+			Name:      "openai",
+			Kind:      zhipu.BackendOpenAI,
+			BaseURL:   server.URL + tt.path,
+			AuthToken: "sk-test",
+		}}
+		config.UsageTracker = tracker
+		c := zhipu.NewClientWithConfig(config)
+
+		stream, err := c.CreateChatCompletionStream(context.Background(), zhipu.ChatCompletionRequest{
+			Model:    zhipu.GLM4,
+			Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var content string
+		var finishes []zhipu.GlmChatCompletionStreamResponse
+		for {
+			chunk, recvErr := stream.Recv()
+			if errors.Is(recvErr, io.EOF) {
+				break
+			}
+			if recvErr != nil {
+				t.Fatal(recvErr)
+			}
+			content += chunk.Choices[0].Delta.Content
+			if chunk.Event == "finish" {
+				finishes = append(finishes, chunk)
+			}
+		}
+		stream.Close()
+
+		if content != "hello" || len(finishes) != 1 || finishes[0].Meta.TaskStatus != "stop" ||
+			finishes[0].Meta.Usage.TotalTokens != tt.usage {
+			t.Fatalf("%s: unexpected stream %q, finish events %+v", tt.path, content, finishes)
+		}
+		if got := tracker.Snapshot().Total.TotalTokens; got != int64(tt.usage) {
+			t.Fatalf("%s: expected %d tracked tokens, got %d", tt.path, tt.usage, got)
+		}
+	}
+}
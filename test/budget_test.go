@@ -0,0 +1,116 @@
+package test_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+func TestBudgetEnforcer(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		fmt.Fprint(w, `{"code":200,"success":true,"data":{"choices":[{"role":"assistant","content":"ok"}],`+
+			`"usage":{"total_tokens":950}}}`)
+	}))
+	defer server.Close()
+
+	budget := zhipu.NewBudgetEnforcer(nil)
+	budget.CompletionEstimate = 100
+	budget.SetBudget("acme", zhipu.Budget{DailyTokens: 1000})
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	config.Budget = budget
+	c := zhipu.NewClientWithConfig(config)
+
+	req := zhipu.ChatCompletionRequest{
+		Model:    zhipu.GLM4,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	}
+	ctx := zhipu.WithTenant(context.Background(), "acme")
+
+	if _, err := c.CreateChatCompletion(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if usage := budget.Usage("acme"); usage.DailyTokens != 950 || usage.MonthlyCost == 0 {
+		t.Fatalf("usage not reconciled: %+v", usage)
+	}
+
+	_, err := c.CreateChatCompletion(ctx, req)
+	var budgetErr *zhipu.BudgetExceededError
+	if !errors.Is(err, zhipu.ErrBudgetExceeded) || !errors.As(err, &budgetErr) || budgetErr.Period != zhipu.BudgetDaily {
+		t.Fatalf("expected daily budget error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("rejected request reached upstream, calls %d", calls)
+	}
+
+	if _, err = c.CreateChatCompletion(context.Background(), req); err != nil {
+		t.Fatalf("request without tenant should not be limited: %v", err)
+	}
+}
+
+func TestBudgetSettlesRequestedModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"id":"c1","model":"gpt-4o-mini","choices":[{"message":{"role":"assistant","content":"ok"}}],`+
+			`"usage":{"total_tokens":1000}}`)
+	}))
+	defer server.Close()
+
+	budget := zhipu.NewBudgetEnforcer(nil)
+	config := zhipu.DefaultConfig("token")
+	config.Backends = []zhipu.Backend{{
+		Name:      "openai",
+		Kind:      zhipu.BackendOpenAI,
+		BaseURL:   server.URL + "/",
+		AuthToken: "sk-test",
+		Models:    map[string]string{zhipu.GLM4: "gpt-4o-mini"},
+	}}
+	config.Budget = budget
+	c := zhipu.NewClientWithConfig(config)
+
+	ctx := zhipu.WithTenant(context.Background(), "acme")
+	if _, err := c.CreateChatCompletion(ctx, zhipu.ChatCompletionRequest{
+		Model:    zhipu.GLM4,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := zhipu.DefaultPricing().Cost(zhipu.GLM4, zhipu.Usage{TotalTokens: 1000}, 0)
+	if usage := budget.Usage("acme"); usage.DailyTokens != 1000 || usage.DailyCost != want {
+		t.Fatalf("expected settlement at %s prices (%g), got %+v", zhipu.GLM4, want, usage)
+	}
+}
+
+func TestBudgetKeepsReservationWithoutUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"id":"c1","model":"glm-4","choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+
+	budget := zhipu.NewBudgetEnforcer(nil)
+	config := zhipu.DefaultConfig("token")
+	config.Backends = []zhipu.Backend{{Name: "openai", Kind: zhipu.BackendOpenAI, BaseURL: server.URL + "/", AuthToken: "sk-test"}}
+	config.Budget = budget
+	c := zhipu.NewClientWithConfig(config)
+
+	ctx := zhipu.WithTenant(context.Background(), "acme")
+	if _, err := c.CreateChatCompletion(ctx, zhipu.ChatCompletionRequest{
+		Model:     zhipu.GLM4,
+		Messages:  []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+		MaxTokens: 100,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if usage := budget.Usage("acme"); usage.DailyTokens != 102 || usage.DailyCost <= 0 {
+		t.Fatalf("expected the reservation to be kept, got %+v", usage)
+	}
+}
@@ -0,0 +1,113 @@
+package test_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+	"github.com/gtkit/go-zhipu/cache"
+)
+
+func TestResponseCache(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		fmt.Fprint(w, `{"code":200,"success":true,"data":{"task_id":"t1",`+
+			`"choices":[{"role":"assistant","content":"positive\nlabel"}],"usage":{"total_tokens":9}}}`)
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	config.Cache = cache.NewLRU(100)
+	c := zhipu.NewClientWithConfig(config)
+
+	req := zhipu.ChatCompletionRequest{
+		Model:    zhipu.Turbo,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "classify"}},
+	}
+	ctx := context.Background()
+
+	first, err := c.CreateChatCompletion(ctx, req)
+	if err != nil || first.Cached {
+		t.Fatalf("first call: %+v %v", first, err)
+	}
+	second, err := c.CreateChatCompletion(ctx, req)
+	if err != nil || !second.Cached || second.Choices[0].Message.Content != "positive\nlabel" || calls != 1 {
+		t.Fatalf("second call: %+v %v, calls %d", second, err, calls)
+	}
+	if _, err = c.CreateChatCompletion(zhipu.WithCacheBypass(ctx), req); err != nil || calls != 2 {
+		t.Fatalf("bypass call: %v, calls %d", err, calls)
+	}
+
+	req.Incremental = true
+	stream, err := c.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateChatCompletionStream: %v", err)
+	}
+	defer stream.Close()
+	if !stream.Cached {
+		t.Fatal("expected stream to be replayed from cache")
+	}
+
+	var content string
+	var last zhipu.GlmChatCompletionStreamResponse
+	for {
+		chunk, recvErr := stream.Recv()
+		if errors.Is(recvErr, io.EOF) {
+			break
+		}
+		if recvErr != nil {
+			t.Fatalf("Recv: %v", recvErr)
+		}
+		content += chunk.Choices[0].Delta.Content
+		last = chunk
+	}
+	if content != "positive\nlabel" || last.Event != "finish" || last.Meta.Usage.TotalTokens != 9 || calls != 2 {
+		t.Fatalf("unexpected replay %q %+v, calls %d", content, last, calls)
+	}
+}
+
+func TestResponseCacheKeyIncludesBackend(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		fmt.Fprintf(w, `{"id":"c%d","model":"m","choices":[{"message":{"role":"assistant","content":"ok"}}],"usage":{"total_tokens":1}}`, calls)
+	}))
+	defer server.Close()
+
+	store := cache.NewLRU(100)
+	newClient := func(model string) zhipu.ChatCompletion[zhipu.ChatCompletionRequest] {
+		config := zhipu.DefaultConfig("token")
+		config.Cache = store
+		config.Backends = []zhipu.Backend{{
+			Name:      "openai",
+			Kind:      zhipu.BackendOpenAI,
+			BaseURL:   server.URL + "/",
+			AuthToken: "sk-test",
+			Models:    map[string]string{zhipu.GLM4: model},
+		}}
+		return zhipu.NewClientWithConfig(config)
+	}
+
+	req := zhipu.ChatCompletionRequest{
+		Model:    zhipu.GLM4,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	}
+	ctx := context.Background()
+	if _, err := newClient("gpt-4o-mini").CreateChatCompletion(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := newClient("gpt-4o").CreateChatCompletion(ctx, req)
+	if err != nil || resp.Cached || calls != 2 {
+		t.Fatalf("clients with different backends should not share entries: %+v %v, calls %d", resp, err, calls)
+	}
+	if resp, err = newClient("gpt-4o").CreateChatCompletion(ctx, req); err != nil || !resp.Cached || calls != 2 {
+		t.Fatalf("expected cache hit: %+v %v, calls %d", resp, err, calls)
+	}
+}
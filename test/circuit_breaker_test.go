@@ -0,0 +1,132 @@
+package test_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !healthy.Load() && r.URL.Path == "/"+zhipu.GLM4+"/invoke" {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error":{"message":"upstream unavailable"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"success":true,"data":{"task_id":"1",`+
+			`"choices":[{"role":"assistant","content":"hi"}],"usage":{"total_tokens":2}}}`)
+	}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	var transitions []string
+	breaker := zhipu.NewCircuitBreaker(
+		zhipu.WithCircuitFailureRate(0.5, 2),
+		zhipu.WithCircuitOpenTimeout(50*time.Millisecond),
+		zhipu.WithCircuitStateChange(func(key zhipu.CircuitKey, from, to zhipu.CircuitState) {
+			mu.Lock()
+			defer mu.Unlock()
+			transitions = append(transitions, fmt.Sprintf("%s %s->%s", key, from, to))
+		}),
+	)
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	config.CircuitBreaker = breaker
+	c := zhipu.NewClientWithConfig(config)
+
+	request := func(model string) error {
+		_, err := c.CreateChatCompletion(context.Background(), zhipu.ChatCompletionRequest{
+			Model:    model,
+			Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+		})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := request(zhipu.GLM4); err == nil || errors.Is(err, zhipu.ErrCircuitOpen) {
+			t.Fatalf("expected upstream error, got %v", err)
+		}
+	}
+
+	key := zhipu.CircuitKey{Backend: "zhipu", Model: zhipu.GLM4, Endpoint: "/invoke"}
+	err := request(zhipu.GLM4)
+	var openErr *zhipu.CircuitOpenError
+	if !errors.Is(err, zhipu.ErrCircuitOpen) || !errors.As(err, &openErr) || openErr.Key != key || openErr.RetryAfter <= 0 {
+		t.Fatalf("expected open circuit, got %v", err)
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("open circuit should not reach upstream, got %d requests", hits.Load())
+	}
+	if state := breaker.State(zhipu.CircuitKey{Backend: "zhipu", Model: zhipu.GLM4, Endpoint: "/sse-invoke"}); state != zhipu.CircuitClosed {
+		t.Fatalf("stream endpoint should be tracked separately, got %s", state)
+	}
+	if err = request(zhipu.GLM3Turbo); err != nil {
+		t.Fatalf("other models should not be affected: %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	healthy.Store(true)
+	if err = request(zhipu.GLM4); err != nil {
+		t.Fatalf("half-open probe: %v", err)
+	}
+	if state := breaker.State(key); state != zhipu.CircuitClosed {
+		t.Fatalf("expected closed after successful probe, got %s", state)
+	}
+
+	want := []string{
+		"zhipu:glm-4/invoke closed->open",
+		"zhipu:glm-4/invoke open->half-open",
+		"zhipu:glm-4/invoke half-open->closed",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(transitions, want) {
+		t.Fatalf("unexpected transitions: %q", transitions)
+	}
+}
+
+func TestCircuitBreakerStreamFirstEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	breaker := zhipu.NewCircuitBreaker(zhipu.WithCircuitFailureRate(1, 1), zhipu.WithCircuitOpenTimeout(time.Minute))
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	config.CircuitBreaker = breaker
+	config.StreamTimeouts = zhipu.StreamTimeouts{FirstEvent: 20 * time.Millisecond}
+	c := zhipu.NewClientWithConfig(config)
+
+	request := zhipu.ChatCompletionRequest{
+		Model:    zhipu.GLM4,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	}
+	stream, err := c.CreateChatCompletionStream(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Recv(); !errors.Is(err, zhipu.ErrFirstTokenTimeout) {
+		t.Fatalf("expected first event timeout, got %v", err)
+	}
+	stream.Close()
+
+	if _, err = c.CreateChatCompletionStream(context.Background(), request); !errors.Is(err, zhipu.ErrCircuitOpen) {
+		t.Fatalf("expected open circuit after first event timeout, got %v", err)
+	}
+}
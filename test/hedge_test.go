@@ -0,0 +1,139 @@
+package test_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+// newHedgeServer 第奇数个请求在 slow 后才返回(slow 为 0 时直到请求被取消), 第偶数个请求立即返回.
+func newHedgeServer(t *testing.T, slow time.Duration, cancelled chan<- struct{}) *httptest.Server {
+	t.Helper()
+	var count atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 读完请求体后服务端才能感知客户端断开.
+		_, _ = io.Copy(io.Discard, r.Body)
+		n := count.Add(1)
+		stream := r.URL.Path == "/"+zhipu.Turbo+"/sse-invoke"
+		if stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.(http.Flusher).Flush()
+		}
+		if n%2 == 1 {
+			if slow == 0 {
+				<-r.Context().Done()
+				cancelled <- struct{}{}
+				return
+			}
+			time.Sleep(slow)
+		}
+
+		if stream {
+			fmt.Fprintf(w, "event:add\nid:%d\ndata:hi\n\n", n)
+			fmt.Fprintf(w, "event:finish\nid:%d\ndata:!\nmeta:{\"usage\":{\"total_tokens\":2}}\n\n", n)
+			return
+		}
+		fmt.Fprintf(w, `{"code":200,"success":true,"data":{"task_id":"%d",`+
+			`"choices":[{"role":"assistant","content":"hi"}],"usage":{"total_tokens":2}}}`, n)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newHedgedClient(server *httptest.Server, policy *zhipu.HedgePolicy) zhipu.ChatCompletion[zhipu.ChatCompletionRequest] {
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	config.Hedge = policy
+	return zhipu.NewClientWithConfig(config)
+}
+
+var hedgeRequest = zhipu.ChatCompletionRequest{
+	Model:    zhipu.Turbo,
+	Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+}
+
+func TestHedgedChatCompletion(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+	policy := zhipu.NewHedgePolicy(20*time.Millisecond, 0)
+	c := newHedgedClient(newHedgeServer(t, 0, cancelled), policy)
+
+	resp, err := c.CreateChatCompletion(context.Background(), hedgeRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "2" {
+		t.Fatalf("expected the hedged request to win, got %q", resp.ID)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow request was not cancelled")
+	}
+	if stats := policy.Stats(); stats != (zhipu.HedgeStats{Requests: 1, Hedged: 1, HedgeWon: 1}) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestHedgedChatCompletionStream(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+	policy := zhipu.NewHedgePolicy(20*time.Millisecond, 0)
+	c := newHedgedClient(newHedgeServer(t, 0, cancelled), policy)
+
+	stream, err := c.CreateChatCompletionStream(context.Background(), hedgeRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	var content string
+	for {
+		resp, recvErr := stream.Recv()
+		if errors.Is(recvErr, io.EOF) {
+			break
+		}
+		if recvErr != nil {
+			t.Fatal(recvErr)
+		}
+		if resp.ID != "2" {
+			t.Fatalf("expected the hedged stream to win, got %q", resp.ID)
+		}
+		content += resp.Choices[0].Delta.Content
+	}
+	if content != "hi!" {
+		t.Fatalf("unexpected content %q", content)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow stream was not cancelled")
+	}
+	if stats := policy.Stats(); stats.Hedged != 1 || stats.HedgeWon != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestHedgeRateLimit(t *testing.T) {
+	policy := zhipu.NewHedgePolicy(20*time.Millisecond, 1)
+	c := newHedgedClient(newHedgeServer(t, 100*time.Millisecond, nil), policy)
+
+	for _, want := range []string{"2", "3"} {
+		resp, err := c.CreateChatCompletion(context.Background(), hedgeRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.ID != want {
+			t.Fatalf("expected response %s, got %s", want, resp.ID)
+		}
+	}
+	if stats := policy.Stats(); stats != (zhipu.HedgeStats{Requests: 2, Hedged: 1, HedgeWon: 1, Throttled: 1}) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
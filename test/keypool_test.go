@@ -0,0 +1,128 @@
+package test_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/gtkit/go-zhipu"
+)
+
+func TestKeyPoolQuarantinesThrottledKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := jwt.MapClaims{}
+		_, _, err := new(jwt.Parser).ParseUnverified(r.Header.Get("Authorization"), claims)
+		if err != nil {
+			t.Errorf("parse token: %v", err)
+		}
+		if claims["api_key"] == "busy" {
+			fmt.Fprint(w, `{"code":1302,"msg":"API 请求并发数超过上限","success":false}`)
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"success":true,"data":{"choices":[{"role":"assistant","content":"ok"}]}}`)
+	}))
+	defer server.Close()
+
+	pool, err := zhipu.NewKeyPool([]string{"busy.secret1", "idle.secret2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := zhipu.DefaultConfigWithKeyPool(pool)
+	config.BaseURL = server.URL + "/"
+	c := zhipu.NewClientWithConfig(config)
+
+	req := zhipu.ChatCompletionRequest{
+		Model:    zhipu.Turbo,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	}
+
+	// 第一个密钥被限流时换下一个密钥重新发送, 调用方无感知.
+	for i := 0; i < 3; i++ {
+		if _, err = c.CreateChatCompletion(context.Background(), req); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+
+	usage := pool.Usage()
+	if usage[0].Key != "busy" || usage[0].Requests != 1 || usage[0].Throttled != 1 || usage[0].QuarantinedUntil.IsZero() {
+		t.Fatalf("unexpected busy key usage: %+v", usage[0])
+	}
+	if usage[1].Requests != 3 || usage[1].Failures != 0 {
+		t.Fatalf("unexpected idle key usage: %+v", usage[1])
+	}
+	if strings.Contains(fmt.Sprint(usage), "secret") {
+		t.Fatalf("usage must not expose secrets: %+v", usage)
+	}
+}
+
+func TestKeyPoolKeepsOpenAIBackendAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer sk-test" {
+			t.Errorf("unexpected Authorization %q", auth)
+		}
+		fmt.Fprint(w, `{"id":"c1","object":"chat.completion","created":1,"model":"gpt-4o-mini",`+
+			`"choices":[{"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	pool, err := zhipu.NewKeyPool([]string{"id.secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := zhipu.DefaultConfigWithKeyPool(pool)
+	config.Backends = []zhipu.Backend{{Name: "openai", Kind: zhipu.BackendOpenAI, BaseURL: server.URL + "/", AuthToken: "sk-test"}}
+	c := zhipu.NewClientWithConfig(config)
+
+	if _, err = c.CreateChatCompletion(context.Background(), zhipu.ChatCompletionRequest{
+		Model:    zhipu.GLM4,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if usage := pool.Usage(); usage[0].Requests != 0 {
+		t.Fatalf("pool must not sign requests to the openai backend: %+v", usage[0])
+	}
+}
+
+func TestKeyPoolExhausted(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/"+zhipu.Turbo+"/sse-invoke" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		fmt.Fprint(w, `{"code":1302,"msg":"API 请求并发数超过上限","success":false}`)
+	}))
+	defer server.Close()
+
+	pool, err := zhipu.NewKeyPool([]string{"a.secret1", "b.secret2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := zhipu.DefaultConfigWithKeyPool(pool)
+	config.BaseURL = server.URL + "/"
+	c := zhipu.NewClientWithConfig(config)
+
+	req := zhipu.ChatCompletionRequest{
+		Model:    zhipu.Turbo,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	}
+	_, err = c.CreateChatCompletion(context.Background(), req)
+	var apiErr *zhipu.APIError
+	if !errors.Is(err, zhipu.ErrNoAvailableKey) || !errors.As(err, &apiErr) || apiErr.ZhipuCode() != 1302 {
+		t.Fatalf("expected ErrNoAvailableKey with the last 1302 error, got %v", err)
+	}
+	if requests.Load() != 2 {
+		t.Fatalf("expected one request per key, got %d", requests.Load())
+	}
+
+	if _, err = c.CreateChatCompletionStream(context.Background(), req); !errors.Is(err, zhipu.ErrNoAvailableKey) {
+		t.Fatalf("expected ErrNoAvailableKey for stream, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package test_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+func TestChatCompletionMessageJSON(t *testing.T) {
+	plain, err := json.Marshal(zhipu.ChatCompletionMessage{Role: zhipu.ChatMessageRoleUser, Content: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != `{"role":"user","content":"hi"}` {
+		t.Fatalf("unexpected string content: %s", plain)
+	}
+
+	msg := zhipu.ChatCompletionMessage{
+		Role: zhipu.ChatMessageRoleUser,
+		MultiContent: []zhipu.ChatMessagePart{
+			zhipu.NewTextPart("describe"),
+			zhipu.NewImageURLPart("https://example.com/a.png"),
+		},
+	}
+	multi, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"role":"user","content":[{"type":"text","text":"describe"},` +
+		`{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]}`
+	if string(multi) != want {
+		t.Fatalf("unexpected multi content:\n got %s\nwant %s", multi, want)
+	}
+
+	var decoded zhipu.ChatCompletionMessage
+	if err = json.Unmarshal(multi, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Content != "" || len(decoded.MultiContent) != 2 || decoded.MultiContent[1].ImageURL.URL != "https://example.com/a.png" {
+		t.Fatalf("unexpected decoded message: %+v", decoded)
+	}
+
+	if err = json.Unmarshal(plain, &decoded); err != nil || decoded.Content != "hi" || decoded.MultiContent != nil {
+		t.Fatalf("unexpected decoded message: %+v, %v", decoded, err)
+	}
+
+	msg.Content = "both"
+	if _, err = json.Marshal(msg); !errors.Is(err, zhipu.ErrContentFieldsMisused) {
+		t.Fatalf("expected ErrContentFieldsMisused, got %v", err)
+	}
+}
+
+func TestNewImagePartFromReader(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	part, err := zhipu.NewImagePartFromReader(bytes.NewReader(png))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(part.ImageURL.URL, "data:image/png;base64,") {
+		t.Fatalf("unexpected data uri: %s", part.ImageURL.URL)
+	}
+
+	if _, err = zhipu.NewImagePartFromReader(strings.NewReader("plain text")); !errors.Is(err, zhipu.ErrUnsupportedImageType) {
+		t.Fatalf("expected ErrUnsupportedImageType, got %v", err)
+	}
+
+	large := append(append([]byte{}, png...), make([]byte, zhipu.MaxImageSize)...)
+	if _, err = zhipu.NewImagePartFromReader(bytes.NewReader(large)); !errors.Is(err, zhipu.ErrImageTooLarge) {
+		t.Fatalf("expected ErrImageTooLarge, got %v", err)
+	}
+}
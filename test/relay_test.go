@@ -0,0 +1,85 @@
+package test_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+func TestRelayStream(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "event:add\nid:1\ndata:line one\n\n")
+		fmt.Fprint(w, "event:finish\nid:1\ndata:!\nmeta:{\"task_id\":\"1\",\"usage\":{\"total_tokens\":4}}\n\n")
+	}))
+	defer upstream.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = upstream.URL + "/"
+	c := zhipu.NewClientWithConfig(config)
+
+	relay := httptest.NewServer(zhipu.RelayHandler(func(r *http.Request) (*zhipu.GlmChatCompletionStream, error) {
+		return c.CreateChatCompletionStream(r.Context(), zhipu.ChatCompletionRequest{
+			Model:    zhipu.Turbo,
+			Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+		})
+	}, zhipu.RelayOptions{DeltaEvent: "token", Raw: true}))
+	defer relay.Close()
+
+	resp, err := http.Get(relay.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "event: token\ndata: line one\n\n" +
+		"event: token\ndata: !\n\n" +
+		"event: usage\ndata: {\"task_status\":\"\",\"usage\":{\"total_tokens\":4},\"task_id\":\"1\",\"request_id\":\"\"}\n\n" +
+		"event: done\ndata: [DONE]\n\n"
+	if resp.Header.Get("Content-Type") != "text/event-stream" || string(body) != want {
+		t.Fatalf("unexpected relay output:\n%s", body)
+	}
+}
+
+func TestRelayStreamStopsOnDisconnect(t *testing.T) {
+	closed := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "event:add\nid:1\ndata:partial\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(closed)
+	}))
+	defer upstream.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = upstream.URL + "/"
+	c := zhipu.NewClientWithConfig(config)
+
+	stream, err := c.CreateChatCompletionStream(context.Background(), zhipu.ChatCompletionRequest{
+		Model:    zhipu.Turbo,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := httptest.NewRecorder()
+	done := make(chan error)
+	go func() { done <- zhipu.RelayStream(ctx, rec, stream, zhipu.RelayOptions{Raw: true}) }()
+
+	cancel()
+	if err = <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	<-closed
+}
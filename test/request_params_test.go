@@ -0,0 +1,83 @@
+package test_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+func TestRequestParameters(t *testing.T) {
+	data, err := json.Marshal(zhipu.ChatCompletionRequest{
+		Model:       zhipu.GLM4,
+		Temperature: zhipu.Float32(0),
+		DoSample:    zhipu.Bool(false),
+		Seed:        zhipu.Int(0),
+		MaxTokens:   256,
+		Stop:        []string{"\n\n"},
+		UserID:      "u1",
+		ReturnType:  zhipu.ReturnTypeText,
+		SensitiveWordCheck: &zhipu.SensitiveWordCheck{
+			Type:   "ALL",
+			Status: "DISABLE",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]any
+	if err = json.Unmarshal(data, &body); err != nil {
+		t.Fatal(err)
+	}
+	// 零值参数需要原样发送, 未设置的参数不发送.
+	if body["temperature"] != 0.0 || body["do_sample"] != false || body["seed"] != 0.0 || body["max_tokens"] != 256.0 ||
+		body["user_id"] != "u1" || body["return_type"] != "text" || body["sensitive_word_check"] == nil {
+		t.Fatalf("unexpected request body: %s", data)
+	}
+	if _, ok := body["top_p"]; ok {
+		t.Fatalf("unset top_p was sent: %s", data)
+	}
+	if _, ok := body["request_id"]; ok {
+		t.Fatalf("unset request_id was sent: %s", data)
+	}
+}
+
+func TestRequestParametersOpenAIBackend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if body["user"] != "u1" || body["max_tokens"] != 16.0 || body["seed"] != 7.0 {
+			t.Errorf("parameters not forwarded: %v", body)
+		}
+		for _, key := range []string{"user_id", "do_sample", "request_id"} {
+			if _, ok := body[key]; ok {
+				t.Errorf("zhipu parameter %s forwarded: %v", key, body)
+			}
+		}
+		fmt.Fprint(w, `{"id":"c1","model":"gpt-4o-mini","choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.Backends = []zhipu.Backend{{Name: "openai", Kind: zhipu.BackendOpenAI, BaseURL: server.URL + "/"}}
+	c := zhipu.NewClientWithConfig(config)
+
+	_, err := c.CreateChatCompletion(context.Background(), zhipu.ChatCompletionRequest{
+		Model:     zhipu.GLM4,
+		Messages:  []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+		MaxTokens: 16,
+		Seed:      zhipu.Int(7),
+		DoSample:  zhipu.Bool(true),
+		RequestID: "r1",
+		UserID:    "u1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
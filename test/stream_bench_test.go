@@ -0,0 +1,66 @@
+package test_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// BenchmarkStreamRecv 读取包含 1000 个增量事件的长回复, 不经过网络.
+func BenchmarkStreamRecv(b *testing.B) {
+	const events = 1000
+	var body strings.Builder
+	for i := 0; i < events; i++ {
+		body.WriteString("event:add\nid:8241957375823750000\ndata:这是一段比较长的增量内容 token\n\n")
+	}
+	body.WriteString("event:finish\nid:8241957375823750000\ndata:\nmeta:{\"usage\":{\"total_tokens\":1000}}\n\n")
+	stream := []byte(body.String())
+
+	config := zhipu.DefaultConfig("token")
+	config.HTTPClient = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       io.NopCloser(bytes.NewReader(stream)),
+		}, nil
+	})}
+	c := zhipu.NewClientWithConfig(config)
+	request := zhipu.ChatCompletionRequest{
+		Model:    zhipu.GLM4,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	}
+
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s, err := c.CreateChatCompletionStream(context.Background(), request)
+		if err != nil {
+			b.Fatal(err)
+		}
+		n := 0
+		for {
+			if _, err = s.Recv(); err != nil {
+				break
+			}
+			n++
+		}
+		s.Close()
+		if !errors.Is(err, io.EOF) || n != events+1 {
+			b.Fatalf("read %d events, err %v", n, err)
+		}
+	}
+	b.ReportMetric(float64(events+1), "events/op")
+}
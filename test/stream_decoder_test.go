@@ -0,0 +1,52 @@
+package test_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+	"github.com/gtkit/go-zhipu/sse"
+)
+
+func TestJSONStreamDecoder(t *testing.T) {
+	type chunk struct {
+		ID      string `json:"id"`
+		Choices []struct {
+			Delta struct {
+				Content string `json:"content"`
+			} `json:"delta"`
+		} `json:"choices"`
+	}
+
+	events := sse.NewDecoder(strings.NewReader(
+		"data: {\"id\":\"c1\",\"choices\":[{\"delta\":{\"content\":\"he\"}}]}\n\n" +
+			"data: {\"id\":\"c1\",\"choices\":[{\"delta\":{\"content\":\"llo\"}}]}\n\n" +
+			"data: [DONE]\n\n"))
+
+	var (
+		decoder zhipu.StreamDecoder[chunk] = zhipu.JSONStreamDecoder[chunk]{}
+		content strings.Builder
+	)
+	for {
+		event, err := events.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, done, err := decoder.Decode(event)
+		if errors.Is(err, io.EOF) {
+			if !done {
+				t.Fatal("expected done with io.EOF")
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content.WriteString(c.Choices[0].Delta.Content)
+	}
+	if content.String() != "hello" {
+		t.Fatalf("unexpected content %q", content.String())
+	}
+}
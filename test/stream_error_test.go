@@ -0,0 +1,100 @@
+package test_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+func TestStreamJSONErrorBody(t *testing.T) {
+	const body = `{"code":1261,"msg":"Prompt 超长","success":false}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json/sse-invoke":
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			fmt.Fprint(w, body)
+		case "/untyped/sse-invoke":
+			// 未声明 Content-Type 时由流读取器识别.
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, body)
+		case "/garbage/sse-invoke":
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event:add\nid:1\n<html>bad gateway</html>\ndata:ok\n\nevent:finish\nid:1\ndata:\n\n")
+		}
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	c := zhipu.NewClientWithConfig(config)
+	request := func(model string) zhipu.ChatCompletionRequest {
+		return zhipu.ChatCompletionRequest{
+			Model:    model,
+			Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+		}
+	}
+
+	var apiErr *zhipu.APIError
+	_, err := c.CreateChatCompletionStream(context.Background(), request("json"))
+	if !errors.As(err, &apiErr) || apiErr.ZhipuCode() != 1261 || apiErr.Message != "Prompt 超长" {
+		t.Fatalf("expected APIError 1261, got %v", err)
+	}
+
+	stream, err := c.CreateChatCompletionStream(context.Background(), request("untyped"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.Recv()
+	stream.Close()
+	if !errors.As(err, &apiErr) || apiErr.ZhipuCode() != 1261 {
+		t.Fatalf("expected APIError 1261 from Recv, got %v", err)
+	}
+	if string(stream.Unparsed()) != body+"\n" {
+		t.Fatalf("unexpected unparsed lines %q", stream.Unparsed())
+	}
+
+	stream, err = c.CreateChatCompletionStream(context.Background(), request("garbage"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	resp, err := stream.Recv()
+	if err != nil || resp.Choices[0].Delta.Content != "ok" {
+		t.Fatalf("unexpected response %+v, %v", resp, err)
+	}
+	if string(stream.Unparsed()) != "<html>bad gateway</html>\n" {
+		t.Fatalf("unexpected unparsed lines %q", stream.Unparsed())
+	}
+}
+
+func TestStreamInvalidMeta(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event:finish\nid:1\ndata:ok\nmeta:{\"usage\":\n\n")
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	c := zhipu.NewClientWithConfig(config)
+	stream, err := c.CreateChatCompletionStream(context.Background(), zhipu.ChatCompletionRequest{
+		Model:    zhipu.GLM4,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	resp, err := stream.Recv()
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) || resp.Choices[0].Delta.Content != "ok" {
+		t.Fatalf("expected meta error with the event content, got %+v %v", resp, err)
+	}
+}
@@ -0,0 +1,145 @@
+package test_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+func TestStreamTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		flusher.Flush()
+		switch r.URL.Path {
+		case "/idle/sse-invoke":
+			fmt.Fprint(w, "event:add\nid:1\ndata:hi\n\n")
+			flusher.Flush()
+		case "/trickle/sse-invoke":
+			for i := 0; i < 100; i++ {
+				fmt.Fprint(w, "event:add\nid:1\ndata:.\n\n")
+				flusher.Flush()
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		model    string
+		timeouts zhipu.StreamTimeouts
+		want     error
+	}{
+		{model: "first", timeouts: zhipu.StreamTimeouts{FirstEvent: 50 * time.Millisecond}, want: zhipu.ErrFirstTokenTimeout},
+		{model: "idle", timeouts: zhipu.StreamTimeouts{FirstEvent: time.Second, Idle: 50 * time.Millisecond}, want: zhipu.ErrStreamIdleTimeout},
+		{model: "trickle", timeouts: zhipu.StreamTimeouts{Idle: time.Second, Total: 100 * time.Millisecond}, want: zhipu.ErrStreamTimeout},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.model, func(t *testing.T) {
+			config := zhipu.DefaultConfig("token")
+			config.BaseURL = server.URL + "/"
+			config.StreamTimeouts = tt.timeouts
+			c := zhipu.NewClientWithConfig(config)
+
+			stream, err := c.CreateChatCompletionStream(context.Background(), zhipu.ChatCompletionRequest{
+				Model:    tt.model,
+				Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+
+			start := time.Now()
+			for {
+				if _, err = stream.Recv(); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Fatalf("timeout took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestStreamFirstEventTimeoutCoversHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		// 读完请求体后服务端才能感知客户端断开.
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	config.StreamTimeouts = zhipu.StreamTimeouts{FirstEvent: 50 * time.Millisecond}
+	c := zhipu.NewClientWithConfig(config)
+
+	start := time.Now()
+	_, err := c.CreateChatCompletionStream(context.Background(), zhipu.ChatCompletionRequest{
+		Model:    zhipu.Turbo,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	})
+	if !errors.Is(err, zhipu.ErrFirstTokenTimeout) {
+		t.Fatalf("expected ErrFirstTokenTimeout before headers, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("timeout took %v", elapsed)
+	}
+}
+
+func TestStreamCloseDuringRecv(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	config.StreamTimeouts = zhipu.StreamTimeouts{FirstEvent: time.Second, Total: time.Minute}
+	c := zhipu.NewClientWithConfig(config)
+
+	for i := 0; i < 20; i++ {
+		stream, err := c.CreateChatCompletionStream(context.Background(), zhipu.ChatCompletionRequest{
+			Model:    zhipu.GLM4,
+			Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 与 RelayStream 一样在另一个 goroutine 中关闭流以唤醒阻塞的 Recv.
+		delay := time.Duration(i%4) * time.Millisecond
+		go func() {
+			time.Sleep(delay)
+			stream.Close()
+		}()
+		start := time.Now()
+		_, err = stream.Recv()
+		if err == nil || errors.Is(err, zhipu.ErrFirstTokenTimeout) || errors.Is(err, zhipu.ErrStreamTimeout) {
+			t.Fatalf("expected a read error after Close, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Fatalf("Close did not wake Recv, took %v", elapsed)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package test_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+type sentiment struct {
+	Label string  `json:"label" enum:"positive,negative"`
+	Score float64 `json:"score"`
+}
+
+func TestCreateStructuredRetriesInvalidOutput(t *testing.T) {
+	answers := []string{
+		`{"label":"great"}`,
+		"```json\n{\"label\":\"positive\",\"score\":0.9}\n```",
+	}
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req zhipu.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if req.ResponseFormat == nil || req.ResponseFormat.Type != zhipu.ChatCompletionResponseFormatTypeJSONObject {
+			t.Errorf("missing response_format: %+v", req.ResponseFormat)
+		}
+		if !strings.Contains(req.Messages[0].Content, `"enum":["positive","negative"]`) {
+			t.Errorf("schema not injected: %s", req.Messages[0].Content)
+		}
+		if calls == 1 && (len(req.Messages) != 3 || !strings.Contains(req.Messages[2].Content, "required field is missing")) {
+			t.Errorf("validation error not fed back: %+v", req.Messages)
+		}
+
+		content, _ := json.Marshal(answers[calls])
+		calls++
+		fmt.Fprintf(w, `{"code":200,"success":true,"data":{"choices":[{"role":"assistant","content":%s}]}}`, content)
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	c := zhipu.NewClientWithConfig(config)
+
+	req := zhipu.ChatCompletionRequest{
+		Model:    zhipu.Turbo,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "今天天气真好"}},
+	}
+	got, err := zhipu.CreateStructured[sentiment](context.Background(), c, req, zhipu.WithMaxAttempts(2))
+	if err != nil {
+		t.Fatalf("CreateStructured: %v", err)
+	}
+	if got.Label != "positive" || got.Score != 0.9 || calls != 2 {
+		t.Fatalf("unexpected result %+v after %d calls", got, calls)
+	}
+}
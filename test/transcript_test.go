@@ -0,0 +1,173 @@
+package test_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+func readAll(t *testing.T, stream *zhipu.GlmChatCompletionStream) []zhipu.GlmChatCompletionStreamResponse {
+	t.Helper()
+	defer stream.Close()
+	var events []zhipu.GlmChatCompletionStreamResponse
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, resp)
+	}
+}
+
+func TestTranscriptRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event:add\nid:1\ndata:你\ndata:好\n\n")
+		w.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprint(w, "event:finish\nid:1\ndata:!\nmeta:{\"usage\":{\"total_tokens\":3}}\n\n")
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	c := zhipu.NewClientWithConfig(config)
+
+	var transcript bytes.Buffer
+	ctx := zhipu.WithTranscript(context.Background(), zhipu.NewTranscriptWriter(&transcript))
+	stream, err := c.CreateChatCompletionStream(ctx, zhipu.ChatCompletionRequest{
+		Model:    zhipu.GLM4,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := readAll(t, stream)
+	if len(live) != 2 || live[0].Choices[0].Delta.Content != "你\n好" {
+		t.Fatalf("unexpected live events %+v", live)
+	}
+
+	var (
+		records []zhipu.TranscriptRecord
+		raw     bytes.Buffer
+		last    time.Duration
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(transcript.Bytes()))
+	for scanner.Scan() {
+		var record zhipu.TranscriptRecord
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Offset < last {
+			t.Fatalf("offsets not increasing: %v after %v", record.Offset, last)
+		}
+		last = record.Offset
+		raw.Write(record.Raw)
+		records = append(records, record)
+	}
+	if records[0].Backend != "zhipu" || records[0].Format != "zhipu" || records[len(records)-1].Error != "EOF" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	if raw.String() != "event:add\nid:1\ndata:你\ndata:好\n\nevent:finish\nid:1\ndata:!\nmeta:{\"usage\":{\"total_tokens\":3}}\n\n" {
+		t.Fatalf("raw bytes not recorded exactly: %q", raw.String())
+	}
+	if last < 50*time.Millisecond {
+		t.Fatalf("offsets not relative to request start: %v", last)
+	}
+
+	replay, err := zhipu.ReplayTranscript(bytes.NewReader(transcript.Bytes()), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed := readAll(t, replay); !reflect.DeepEqual(replayed, live) || replay.Backend != "zhipu" {
+		t.Fatalf("replay differs:\n got %+v\nwant %+v", replayed, live)
+	}
+
+	start := time.Now()
+	replay, err = zhipu.ReplayTranscript(bytes.NewReader(transcript.Bytes()), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readAll(t, replay)
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("realtime replay finished too early: %v", elapsed)
+	}
+}
+
+func TestTranscriptSinkFailureStopsStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "event:add\nid:1\ndata:hi\n\n")
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	c := zhipu.NewClientWithConfig(config)
+
+	errDiskFull := errors.New("disk full")
+	calls := 0
+	sink := zhipu.TranscriptSinkFunc(func(zhipu.TranscriptRecord) error {
+		calls++
+		if calls > 1 {
+			return errDiskFull
+		}
+		return nil
+	})
+	stream, err := c.CreateChatCompletionStream(zhipu.WithTranscript(context.Background(), sink), zhipu.ChatCompletionRequest{
+		Model:    zhipu.GLM4,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if _, err = stream.Recv(); !errors.Is(err, errDiskFull) {
+		t.Fatalf("expected sink error, got %v", err)
+	}
+}
+
+func TestTranscriptEventErrorIsReported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "event:add\nid:1\ndata:hi\n\n")
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	c := zhipu.NewClientWithConfig(config)
+
+	errDiskFull := errors.New("disk full")
+	sink := zhipu.TranscriptSinkFunc(func(record zhipu.TranscriptRecord) error {
+		if record.Event != nil {
+			return errDiskFull
+		}
+		return nil
+	})
+	stream, err := c.CreateChatCompletionStream(zhipu.WithTranscript(context.Background(), sink), zhipu.ChatCompletionRequest{
+		Model:    zhipu.GLM4,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events := readAll(t, stream); len(events) != 1 {
+		t.Fatalf("event recording failure should not stop the stream, got %d events", len(events))
+	}
+	if err = stream.TranscriptErr(); !errors.Is(err, errDiskFull) {
+		t.Fatalf("expected sink error, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package test_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+func TestUsageTracker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/sse-invoke") {
+			fmt.Fprint(w, "event:add\nid:1\ndata:hi\n\n")
+			fmt.Fprint(w, "event:finish\nid:1\ndata:\nmeta:{\"usage\":{\"total_tokens\":3000}}\n\n")
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"success":true,"data":{"choices":[{"role":"assistant","content":"hi"}],`+
+			`"usage":{"total_tokens":1000}}}`)
+	}))
+	defer server.Close()
+
+	tracker := zhipu.NewUsageTracker(nil)
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	config.UsageTracker = tracker
+	c := zhipu.NewClientWithConfig(config)
+
+	req := zhipu.ChatCompletionRequest{
+		Model:    zhipu.GLM4,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	}
+	ctx := zhipu.WithUsageTags(context.Background(), "team-a")
+
+	if _, err := c.CreateChatCompletion(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := c.CreateChatCompletionStream(zhipu.WithUsageTags(ctx, "chat"), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err = stream.Recv(); err != nil {
+			break
+		}
+	}
+	stream.Close()
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("Recv: %v", err)
+	}
+
+	snapshot := tracker.Snapshot()
+	model := snapshot.ByModel[zhipu.GLM4]
+	if model.Requests != 2 || model.TotalTokens != 4000 || math.Abs(model.Cost-0.4) > 1e-9 {
+		t.Fatalf("unexpected model totals: %+v", model)
+	}
+	if snapshot.ByTag["team-a"].Requests != 2 || snapshot.ByTag["chat"].TotalTokens != 3000 {
+		t.Fatalf("unexpected tag totals: %+v", snapshot.ByTag)
+	}
+
+	var buf bytes.Buffer
+	if err = snapshot.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "model,glm-4,2,0,0,4000,0,0.400000,0") {
+		t.Fatalf("unexpected csv:\n%s", buf.String())
+	}
+}
+
+func TestUsageTrackerUsesRequestedModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] == true {
+			fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"delta\":{\"content\":\"hi\"},\"finish_reason\":\"stop\"}],"+
+				"\"usage\":{\"total_tokens\":3}}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		fmt.Fprint(w, `{"id":"c1","model":"gpt-4o-mini-2024-07-18","choices":[{"message":{"role":"assistant","content":"hi"}}],`+
+			`"usage":{"total_tokens":2}}`)
+	}))
+	defer server.Close()
+
+	tracker := zhipu.NewUsageTracker(nil)
+	config := zhipu.DefaultConfig("token")
+	config.Backends = []zhipu.Backend{{
+		Name:      "openai",
+		Kind:      zhipu.BackendOpenAI,
+		BaseURL:   server.URL + "/",
+		AuthToken: "sk-test",
+		Models:    map[string]string{zhipu.GLM4: "gpt-4o-mini"},
+	}}
+	config.UsageTracker = tracker
+	c := zhipu.NewClientWithConfig(config)
+
+	req := zhipu.ChatCompletionRequest{
+		Model:    zhipu.GLM4,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "hi"}},
+	}
+	if _, err := c.CreateChatCompletion(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := c.CreateChatCompletionStream(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	stream.Close()
+
+	snapshot := tracker.Snapshot()
+	if len(snapshot.ByModel) != 1 || snapshot.ByModel[zhipu.GLM4].Requests != 2 || snapshot.ByModel[zhipu.GLM4].TotalTokens != 5 {
+		t.Fatalf("expected both calls under %s, got %+v", zhipu.GLM4, snapshot.ByModel)
+	}
+}
@@ -0,0 +1,135 @@
+package test_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+func TestValidate(t *testing.T) {
+	user := zhipu.ChatCompletionMessage{Role: zhipu.ChatMessageRoleUser, Content: "hi"}
+
+	tests := []struct {
+		name    string
+		request zhipu.ChatCompletionRequest
+		fields  []string
+	}{
+		{
+			name:    "valid",
+			request: zhipu.ChatCompletionRequest{Model: zhipu.GLM4, Messages: []zhipu.ChatCompletionMessage{user}},
+		},
+		{
+			name: "ends with tool result",
+			request: zhipu.ChatCompletionRequest{Model: zhipu.GLM4, Messages: []zhipu.ChatCompletionMessage{
+				user,
+				{Role: zhipu.ChatMessageRoleAssistant, ToolCalls: []zhipu.ToolCall{{ID: "call_1"}}},
+				{Role: zhipu.ChatMessageRoleTool, ToolCallID: "call_1", Content: "{}"},
+			}},
+		},
+		{
+			name: "web search on text model",
+			request: zhipu.ChatCompletionRequest{
+				Model:    zhipu.Turbo,
+				Messages: []zhipu.ChatCompletionMessage{user},
+				Tools:    []zhipu.Tool{zhipu.NewWebSearchTool("go", true)},
+			},
+		},
+		{
+			name:    "empty",
+			request: zhipu.ChatCompletionRequest{},
+			fields:  []string{"model", "prompt"},
+		},
+		{
+			name: "every problem listed",
+			request: zhipu.ChatCompletionRequest{
+				Model:       zhipu.GLM4V,
+				Temperature: zhipu.Float32(0),
+				TopP:        zhipu.Float32(1.5),
+				MaxTokens:   4096,
+				Tools: []zhipu.Tool{
+					zhipu.NewWebSearchTool("", true),
+					{Type: zhipu.ToolTypeFunction, Function: &zhipu.FunctionDefinition{Name: "lookup"}},
+				},
+				Messages: []zhipu.ChatCompletionMessage{
+					{Role: "bot", Content: "hi"},
+					{Role: zhipu.ChatMessageRoleAssistant, Content: "hello"},
+				},
+			},
+			fields: []string{"temperature", "top_p", "max_tokens", "tools[1]", "prompt[0].role", "prompt[1].role"},
+		},
+		{
+			name: "image on text model",
+			request: zhipu.ChatCompletionRequest{Model: zhipu.GLM4, Messages: []zhipu.ChatCompletionMessage{{
+				Role:         zhipu.ChatMessageRoleUser,
+				MultiContent: []zhipu.ChatMessagePart{zhipu.NewImageURLPart("https://example.com/a.png")},
+			}}},
+			fields: []string{"prompt[0].content"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.request.Validate()
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var verr *zhipu.ValidationError
+			if !errors.As(err, &verr) || !errors.Is(err, zhipu.ErrInvalidRequest) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+			if len(verr.Errors) != len(tt.fields) {
+				t.Fatalf("expected fields %v, got %v", tt.fields, err)
+			}
+			for i, field := range tt.fields {
+				if verr.Errors[i].Field != field {
+					t.Fatalf("expected fields %v, got %v", tt.fields, err)
+				}
+			}
+		})
+	}
+}
+
+func TestClientValidation(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		fmt.Fprint(w, `{"code":200,"success":true,"data":{"task_id":"t1","choices":[]}}`)
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	c := zhipu.NewClientWithConfig(config)
+
+	request := zhipu.ChatCompletionRequest{Model: zhipu.GLM4}
+	if _, err := c.CreateChatCompletion(context.Background(), request); !errors.Is(err, zhipu.ErrInvalidRequest) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if _, err := c.CreateChatCompletionStream(context.Background(), request); !errors.Is(err, zhipu.ErrInvalidRequest) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Fatal("invalid request was sent")
+	}
+
+	// 关闭校验后请求照常发送, 空 choices 返回 ErrNoChoices 而不是 panic.
+	config.DisableValidation = true
+	c = zhipu.NewClientWithConfig(config)
+	if _, err := c.CreateChatCompletion(context.Background(), request); !errors.Is(err, zhipu.ErrNoChoices) {
+		t.Fatalf("expected ErrNoChoices, got %v", err)
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatal("request was not sent with validation disabled")
+	}
+}
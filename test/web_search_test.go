@@ -0,0 +1,77 @@
+package test_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+func TestWebSearchResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req zhipu.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if len(req.Tools) != 1 || req.Tools[0].WebSearch == nil || req.Tools[0].WebSearch.SearchQuery != "go" {
+			t.Errorf("unexpected tools: %+v", req.Tools)
+		}
+
+		result := `[{"title":"Go","link":"https://go.dev","media":"go.dev","content":"The Go language","refer":"[^1]"}]`
+		switch r.URL.Path {
+		case "/" + zhipu.Turbo + "/invoke":
+			fmt.Fprintf(w, `{"code":200,"msg":"ok","success":true,"data":{"task_id":"1",`+
+				`"choices":[{"role":"assistant","content":"Go[^1]"}],"web_search":%s}}`, result)
+		case "/" + zhipu.Turbo + "/sse-invoke":
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event:add\nid:1\ndata:Go\n\n")
+			fmt.Fprintf(w, "event:finish\nid:1\ndata:[^1]\nmeta:{\"task_id\":\"1\",\"web_search\":%s}\n\n", result)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = server.URL + "/"
+	c := zhipu.NewClientWithConfig(config)
+
+	req := zhipu.ChatCompletionRequest{
+		Model:    zhipu.Turbo,
+		Messages: []zhipu.ChatCompletionMessage{{Role: zhipu.ChatMessageRoleUser, Content: "what is go"}},
+		Tools:    []zhipu.Tool{zhipu.NewWebSearchTool("go", true)},
+	}
+
+	resp, err := c.CreateChatCompletion(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CreateChatCompletion: %v", err)
+	}
+	if len(resp.WebSearch) != 1 || resp.WebSearch[0].Link != "https://go.dev" || resp.WebSearch[0].Refer != "[^1]" {
+		t.Fatalf("unexpected web search results: %+v", resp.WebSearch)
+	}
+
+	stream, err := c.CreateChatCompletionStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CreateChatCompletionStream: %v", err)
+	}
+	defer stream.Close()
+
+	var last zhipu.GlmChatCompletionStreamResponse
+	for i := 0; i < 2; i++ {
+		if last, err = stream.Recv(); err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+	}
+	if last.Event != "finish" || len(last.WebSearch) != 1 || last.WebSearch[0].Title != "Go" {
+		t.Fatalf("unexpected stream response: %+v", last)
+	}
+	if _, err = stream.Recv(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF after finish, got %v", err)
+	}
+}
@@ -39,7 +39,7 @@ func TestZpChat(t *testing.T) {
 	req := zhipu.ChatCompletionRequest{
 		Model:       zhipu.Turbo,
 		Messages:    prompt,
-		Temperature: 0.7,
+		Temperature: zhipu.Float32(0.7),
 		Incremental: true,
 	}
 	jr, _ := json.Marshal(req)
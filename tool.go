@@ -0,0 +1,67 @@
+package zhipu
+
+// Tool type defined by the Zhipu API.
+const (
+	ToolTypeWebSearch = "web_search"
+	ToolTypeFunction  = "function"
+)
+
+// Tool 请求中可供模型使用的工具.
+type Tool struct {
+	Type      string              `json:"type"`
+	WebSearch *WebSearch          `json:"web_search,omitempty"`
+	Function  *FunctionDefinition `json:"function,omitempty"`
+}
+
+// FunctionDefinition function 工具定义, Parameters 为参数的 JSON Schema.
+type FunctionDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters"`
+}
+
+// ToolCall 模型发起的一次工具调用.
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall 调用的函数名以及 JSON 编码的参数.
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// WebSearch web_search 工具参数.
+type WebSearch struct {
+	// Enable 是否启用网络检索, 关闭时模型不会进行检索.
+	Enable bool `json:"enable"`
+	// SearchQuery 自定义检索内容, 不提供时由模型根据对话生成.
+	SearchQuery string `json:"search_query,omitempty"`
+	// SearchResult 是否在响应中返回检索结果.
+	SearchResult bool `json:"search_result,omitempty"`
+}
+
+// WebSearchResult web_search 工具返回的一条检索结果.
+type WebSearchResult struct {
+	Icon    string `json:"icon,omitempty"`
+	Title   string `json:"title"`
+	Link    string `json:"link"`
+	Media   string `json:"media"`
+	Content string `json:"content"`
+	// Refer 角标序号, 例如 "[^1]", 与回答中的引用标记对应.
+	Refer string `json:"refer"`
+}
+
+// NewWebSearchTool 返回一个 web_search 工具, query 为空时由模型自行生成检索词.
+func NewWebSearchTool(query string, searchResult bool) Tool {
+	return Tool{
+		Type: ToolTypeWebSearch,
+		WebSearch: &WebSearch{
+			Enable:       true,
+			SearchQuery:  query,
+			SearchResult: searchResult,
+		},
+	}
+}
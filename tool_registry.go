@@ -0,0 +1,159 @@
+package zhipu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gtkit/go-zhipu/jsonschema"
+)
+
+var (
+	ErrToolNotFound   = errors.New("tool not found")
+	ErrToolRegistered = errors.New("tool already registered")
+)
+
+// ToolHandler 处理一次工具调用, arguments 为模型给出的 JSON 参数.
+type ToolHandler func(ctx context.Context, arguments string) (string, error)
+
+type registeredTool struct {
+	definition FunctionDefinition
+	handler    ToolHandler
+	timeout    time.Duration
+}
+
+// ToolRegistry 保存可供模型调用的 Go 函数, 并发安全.
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]*registeredTool
+	order []string
+}
+
+type toolOptions struct {
+	timeout time.Duration
+}
+
+type ToolOption func(*toolOptions)
+
+// WithToolTimeout 设置单个工具的执行超时, 覆盖 Agent.ToolTimeout.
+func WithToolTimeout(d time.Duration) ToolOption {
+	return func(o *toolOptions) {
+		o.timeout = d
+	}
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools: make(map[string]*registeredTool),
+	}
+}
+
+// RegisterTool 注册一个函数工具, 参数 Schema 由 A 的结构体定义通过反射生成,
+// 调用时模型给出的参数解码为 A 后传入 fn.
+func RegisterTool[A any](
+	r *ToolRegistry,
+	name, description string,
+	fn func(ctx context.Context, args A) (string, error),
+	opts ...ToolOption,
+) error {
+	var zero A
+	schema, err := jsonschema.GenerateSchemaForType(zero)
+	if err != nil {
+		return fmt.Errorf("tool %s, %w", name, err)
+	}
+
+	handler := func(ctx context.Context, arguments string) (string, error) {
+		var args A
+		if arguments != "" {
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return "", fmt.Errorf("invalid arguments, %w", err)
+			}
+		}
+		return fn(ctx, args)
+	}
+
+	return r.Register(FunctionDefinition{
+		Name:        name,
+		Description: description,
+		Parameters:  schema,
+	}, handler, opts...)
+}
+
+// Register 使用自定义的参数 Schema 注册工具.
+func (r *ToolRegistry) Register(definition FunctionDefinition, handler ToolHandler, opts ...ToolOption) error {
+	var options toolOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tools[definition.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrToolRegistered, definition.Name)
+	}
+	r.tools[definition.Name] = &registeredTool{
+		definition: definition,
+		handler:    handler,
+		timeout:    options.timeout,
+	}
+	r.order = append(r.order, definition.Name)
+	return nil
+}
+
+// Tools 按注册顺序返回所有工具定义, 可直接用于 ChatCompletionRequest.Tools.
+func (r *ToolRegistry) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tools := make([]Tool, 0, len(r.order))
+	for _, name := range r.order {
+		definition := r.tools[name].definition
+		tools = append(tools, Tool{Type: ToolTypeFunction, Function: &definition})
+	}
+	return tools
+}
+
+// Call 执行一次工具调用, timeout 为未单独设置超时的工具使用的默认超时, 0 表示不限制.
+func (r *ToolRegistry) Call(ctx context.Context, call ToolCall, timeout time.Duration) (output string, err error) {
+	r.mu.RLock()
+	tool, ok := r.tools[call.Function.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrToolNotFound, call.Function.Name)
+	}
+
+	if tool.timeout > 0 {
+		timeout = tool.timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		output string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{err: fmt.Errorf("tool %s panic: %v", call.Function.Name, p)}
+			}
+		}()
+		out, callErr := tool.handler(ctx, call.Function.Arguments)
+		done <- result{output: out, err: callErr}
+	}()
+
+	select {
+	case res := <-done:
+		return res.output, res.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("tool %s, %w", call.Function.Name, ctx.Err())
+	}
+}
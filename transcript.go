@@ -0,0 +1,219 @@
+package zhipu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gtkit/go-zhipu/sse"
+)
+
+// 流的原始格式, 回放时据此选择解析方式.
+const (
+	transcriptFormatZhipu  = "zhipu"
+	transcriptFormatOpenAI = "openai"
+)
+
+// TranscriptRecord 流记录中的一条. 第一条记录 Backend 与 Format, 之后每条为 Raw、Event 或 Error 之一.
+type TranscriptRecord struct {
+	// Offset 相对请求开始的时间.
+	Offset time.Duration `json:"offset"`
+	// Backend 提供该流的后端名称, 仅第一条记录设置.
+	Backend string `json:"backend,omitempty"`
+	// Format 原始数据的格式, "zhipu" 或 "openai", 仅第一条记录设置.
+	Format string `json:"format,omitempty"`
+	// Raw 从连接读到的原始 SSE 字节.
+	Raw []byte `json:"raw,omitempty"`
+	// Event Recv 返回的事件.
+	Event *GlmChatCompletionStreamResponse `json:"event,omitempty"`
+	// Error Recv 返回的错误, 正常结束时为 "EOF".
+	Error string `json:"error,omitempty"`
+}
+
+// TranscriptSink 保存流记录. 记录原始字节失败时流的读取返回该错误, 保证送达用户的内容都有记录;
+// 记录事件失败时通过 GlmChatCompletionStream.TranscriptErr 返回.
+type TranscriptSink interface {
+	Record(record TranscriptRecord) error
+}
+
+// TranscriptSinkFunc 将函数作为 TranscriptSink.
+type TranscriptSinkFunc func(record TranscriptRecord) error
+
+func (f TranscriptSinkFunc) Record(record TranscriptRecord) error {
+	return f(record)
+}
+
+type transcriptWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewTranscriptWriter 返回以 JSON Lines 格式写入 w 的 TranscriptSink, 可以被多个流并发使用.
+func NewTranscriptWriter(w io.Writer) TranscriptSink {
+	return &transcriptWriter{enc: json.NewEncoder(w)}
+}
+
+func (t *transcriptWriter) Record(record TranscriptRecord) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.enc.Encode(record)
+}
+
+type transcriptKey struct{}
+
+// WithTranscript 返回记录流式调用的 context, CreateChatCompletionStream 返回的流
+// 读取到的原始字节与 Recv 返回的事件都写入 sink.
+func WithTranscript(ctx context.Context, sink TranscriptSink) context.Context {
+	return context.WithValue(ctx, transcriptKey{}, sink)
+}
+
+// transcriptRecorder 记录一个流, 只在调用 Recv 的 goroutine 中使用.
+type transcriptRecorder struct {
+	sink  TranscriptSink
+	start time.Time
+	body  io.Reader
+	// err 记录事件时 sink 返回的第一个错误.
+	err error
+}
+
+// recordTranscript context 中设置了 TranscriptSink 时记录 stream, start 为请求开始时间.
+func recordTranscript(ctx context.Context, stream *GlmChatCompletionStream, start time.Time) error {
+	sink, _ := ctx.Value(transcriptKey{}).(TranscriptSink)
+	if sink == nil {
+		return nil
+	}
+
+	format := transcriptFormatZhipu
+	if _, ok := stream.decoder.(*openAIChunkDecoder); ok {
+		format = transcriptFormatOpenAI
+	}
+	rec := &transcriptRecorder{sink: sink, start: start, body: stream.response.Body}
+	if err := sink.Record(TranscriptRecord{Offset: time.Since(start), Backend: stream.Backend, Format: format}); err != nil {
+		return err
+	}
+
+	stream.setBody(rec)
+	stream.observe(rec.event)
+	stream.transcript = rec
+	return nil
+}
+
+func (r *transcriptRecorder) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if n > 0 {
+		raw := append([]byte(nil), p[:n]...)
+		if recErr := r.sink.Record(TranscriptRecord{Offset: time.Since(r.start), Raw: raw}); recErr != nil {
+			return 0, recErr
+		}
+	}
+	return n, err
+}
+
+func (r *transcriptRecorder) event(response GlmChatCompletionStreamResponse, err error) {
+	record := TranscriptRecord{Offset: time.Since(r.start)}
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		record.Event = &response
+	}
+	if recErr := r.sink.Record(record); recErr != nil && r.err == nil {
+		r.err = recErr
+	}
+}
+
+// TranscriptErr 返回记录 Recv 事件时 TranscriptSink 返回的第一个错误, 未设置 WithTranscript 时为 nil.
+// 事件记录失败不影响流的读取, 应在读取结束后与 Recv 在同一 goroutine 中检查.
+func (s *GlmChatCompletionStream) TranscriptErr() error {
+	if s.transcript == nil {
+		return nil
+	}
+	return s.transcript.err
+}
+
+// ReplayTranscript 读取 NewTranscriptWriter 写入的记录, 将其中的原始字节重新解析为流.
+// realtime 为 true 时按记录的时间间隔返回数据, 用于界面回放; 否则立即返回全部数据.
+func ReplayTranscript(r io.Reader, realtime bool) (*GlmChatCompletionStream, error) {
+	var (
+		header  TranscriptRecord
+		records []TranscriptRecord
+	)
+	dec := json.NewDecoder(r)
+	for first := true; ; first = false {
+		var record TranscriptRecord
+		err := dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first {
+			header = record
+		}
+		if len(record.Raw) > 0 {
+			records = append(records, record)
+		}
+	}
+
+	var decoder StreamDecoder[GlmChatCompletionStreamResponse] = &glmStreamDecoder{}
+	if header.Format == transcriptFormatOpenAI {
+		decoder = &openAIChunkDecoder{}
+	}
+	body := &replayBody{records: records, realtime: realtime, start: time.Now(), closed: make(chan struct{})}
+	return &GlmChatCompletionStream{
+		streamReader: newStreamReader(&http.Response{StatusCode: http.StatusOK, Body: body}, decoder),
+		Backend:      header.Backend,
+	}, nil
+}
+
+// replayBody 依次返回记录的原始字节.
+type replayBody struct {
+	records  []TranscriptRecord
+	pending  []byte
+	realtime bool
+	start    time.Time
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (b *replayBody) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 {
+		if len(b.records) == 0 {
+			return 0, io.EOF
+		}
+		record := b.records[0]
+		b.records = b.records[1:]
+
+		if wait := time.Until(b.start.Add(record.Offset)); b.realtime && wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-b.closed:
+				timer.Stop()
+				return 0, http.ErrBodyReadAfterClose
+			}
+		}
+		b.pending = record.Raw
+	}
+
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+func (b *replayBody) Close() error {
+	b.closeOnce.Do(func() { close(b.closed) })
+	return nil
+}
+
+// setBody 在开始读取前替换解析的数据来源.
+func (stream *streamReader[T]) setBody(r io.Reader) {
+	unknown := stream.events.Unknown
+	stream.events = sse.NewDecoder(r)
+	stream.events.Unknown = unknown
+}
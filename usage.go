@@ -0,0 +1,224 @@
+package zhipu
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type usageTagsKey struct{}
+
+// WithUsageTags 返回携带用量标签的 context, 该 context 发起的调用会同时计入这些标签.
+func WithUsageTags(ctx context.Context, tags ...string) context.Context {
+	existing := usageTags(ctx)
+	merged := make([]string, 0, len(existing)+len(tags))
+	merged = append(append(merged, existing...), tags...)
+	return context.WithValue(ctx, usageTagsKey{}, merged)
+}
+
+func usageTags(ctx context.Context) []string {
+	tags, _ := ctx.Value(usageTagsKey{}).([]string)
+	return tags
+}
+
+// recordUsage 配置了 UsageTracker 时记录一次调用, model 为调用方请求的模型名, 不随后端的模型映射变化.
+func (c *Client) recordUsage(ctx context.Context, model string, usage Usage) {
+	if c.config.UsageTracker != nil {
+		c.config.UsageTracker.Record(model, usage, usageTags(ctx)...)
+	}
+}
+
+// observeStreamUsage 在流返回用量时记录, 每个流只记录一次.
+func (c *Client) observeStreamUsage(ctx context.Context, stream *GlmChatCompletionStream, model string) {
+	if c.config.UsageTracker == nil {
+		return
+	}
+	var recorded bool
+	stream.observe(func(response GlmChatCompletionStreamResponse, err error) {
+		if recorded || err != nil || response.Meta.Usage.TotalTokens == 0 {
+			return
+		}
+		recorded = true
+		c.recordUsage(ctx, model, Usage{TotalTokens: response.Meta.Usage.TotalTokens})
+	})
+}
+
+// UsageTotals 累计的调用次数、tokens 与费用(人民币元).
+type UsageTotals struct {
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Images           int64   `json:"images"`
+	Cost             float64 `json:"cost"`
+	// Unpriced 价格表中没有对应模型、未计入费用的调用次数.
+	Unpriced int64 `json:"unpriced"`
+}
+
+func (t *UsageTotals) add(usage Usage, images int, cost float64, priced bool) {
+	t.Requests++
+	t.PromptTokens += int64(usage.PromptTokens)
+	t.CompletionTokens += int64(usage.CompletionTokens)
+	t.TotalTokens += int64(usage.TotalTokens)
+	t.Images += int64(images)
+	t.Cost += cost
+	if !priced {
+		t.Unpriced++
+	}
+}
+
+// UsageSnapshot 某一时刻的用量统计.
+type UsageSnapshot struct {
+	Since   time.Time              `json:"since"`
+	At      time.Time              `json:"at"`
+	Total   UsageTotals            `json:"total"`
+	ByModel map[string]UsageTotals `json:"by_model"`
+	ByTag   map[string]UsageTotals `json:"by_tag"`
+}
+
+// UsageTracker 按模型和标签统计同步与流式调用的 tokens 与费用, 并发安全.
+type UsageTracker struct {
+	mu      sync.Mutex
+	pricing PricingTable
+	since   time.Time
+	total   UsageTotals
+	byModel map[string]*UsageTotals
+	byTag   map[string]*UsageTotals
+}
+
+// NewUsageTracker 使用价格表创建统计器, pricing 为 nil 时使用 DefaultPricing.
+func NewUsageTracker(pricing PricingTable) *UsageTracker {
+	if pricing == nil {
+		pricing = DefaultPricing()
+	}
+	return &UsageTracker{
+		pricing: pricing,
+		since:   time.Now(),
+		byModel: make(map[string]*UsageTotals),
+		byTag:   make(map[string]*UsageTotals),
+	}
+}
+
+// Pricing 返回统计器使用的价格表.
+func (t *UsageTracker) Pricing() PricingTable {
+	return t.pricing
+}
+
+// Record 记录一次调用的 tokens 用量并返回计算出的费用.
+func (t *UsageTracker) Record(model string, usage Usage, tags ...string) float64 {
+	return t.record(model, usage, 0, tags)
+}
+
+// RecordImages 记录图片生成模型的调用.
+func (t *UsageTracker) RecordImages(model string, images int, tags ...string) float64 {
+	return t.record(model, Usage{}, images, tags)
+}
+
+func (t *UsageTracker) record(model string, usage Usage, images int, tags []string) float64 {
+	cost, priced := t.pricing.Cost(model, usage, images)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total.add(usage, images, cost, priced)
+	totals(t.byModel, model).add(usage, images, cost, priced)
+	for _, tag := range tags {
+		totals(t.byTag, tag).add(usage, images, cost, priced)
+	}
+	return cost
+}
+
+func totals(m map[string]*UsageTotals, key string) *UsageTotals {
+	v, ok := m[key]
+	if !ok {
+		v = &UsageTotals{}
+		m[key] = v
+	}
+	return v
+}
+
+// Snapshot 返回当前统计的副本.
+func (t *UsageTracker) Snapshot() UsageSnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snapshot := UsageSnapshot{
+		Since:   t.since,
+		At:      time.Now(),
+		Total:   t.total,
+		ByModel: make(map[string]UsageTotals, len(t.byModel)),
+		ByTag:   make(map[string]UsageTotals, len(t.byTag)),
+	}
+	for k, v := range t.byModel {
+		snapshot.ByModel[k] = *v
+	}
+	for k, v := range t.byTag {
+		snapshot.ByTag[k] = *v
+	}
+	return snapshot
+}
+
+// Reset 清空统计并返回清空前的快照.
+func (t *UsageTracker) Reset() UsageSnapshot {
+	snapshot := t.Snapshot()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.since = snapshot.At
+	t.total = UsageTotals{}
+	t.byModel = make(map[string]*UsageTotals)
+	t.byTag = make(map[string]*UsageTotals)
+	return snapshot
+}
+
+// WriteJSON 以 JSON 格式导出快照.
+func (s UsageSnapshot) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// WriteCSV 以 CSV 格式导出快照, 每行为一个模型或标签.
+func (s UsageSnapshot) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{
+		"dimension", "name", "requests", "prompt_tokens", "completion_tokens", "total_tokens", "images", "cost", "unpriced",
+	}); err != nil {
+		return err
+	}
+
+	for _, group := range []struct {
+		dimension string
+		totals    map[string]UsageTotals
+	}{{"model", s.ByModel}, {"tag", s.ByTag}} {
+		names := make([]string, 0, len(group.totals))
+		for name := range group.totals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			v := group.totals[name]
+			if err := cw.Write([]string{
+				group.dimension, name,
+				strconv.FormatInt(v.Requests, 10),
+				strconv.FormatInt(v.PromptTokens, 10),
+				strconv.FormatInt(v.CompletionTokens, 10),
+				strconv.FormatInt(v.TotalTokens, 10),
+				strconv.FormatInt(v.Images, 10),
+				strconv.FormatFloat(v.Cost, 'f', 6, 64),
+				strconv.FormatInt(v.Unpriced, 10),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,117 @@
+package zhipu
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidRequest = errors.New("invalid request")
+
+// FieldError 请求中一个字段的问题, Field 为 JSON 路径, 例如 "prompt[1].role".
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) String() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError 请求未通过客户端校验, 列出所有字段问题, errors.Is(err, ErrInvalidRequest) 为 true.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		problems[i] = fe.String()
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidRequest, strings.Join(problems, "; "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidRequest
+}
+
+// Validate 按 DefaultModels 能力表校验请求, 不合法时返回 *ValidationError.
+func (r ChatCompletionRequest) Validate() error {
+	return r.validate(DefaultModels())
+}
+
+func (r ChatCompletionRequest) validate(models ModelTable) error {
+	var errs []FieldError
+	add := func(field, format string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if r.Model == "" {
+		add("model", "is required")
+	}
+	caps, known := models[r.Model]
+
+	if r.Temperature != nil && (*r.Temperature <= 0 || *r.Temperature > 1) {
+		add("temperature", "%g is outside (0, 1]", *r.Temperature)
+	}
+	if r.TopP != nil && (*r.TopP <= 0 || *r.TopP > 1) {
+		add("top_p", "%g is outside (0, 1]", *r.TopP)
+	}
+	if r.MaxTokens < 0 {
+		add("max_tokens", "must not be negative")
+	} else if known && caps.MaxTokens > 0 && r.MaxTokens > caps.MaxTokens {
+		add("max_tokens", "%d exceeds the %d supported by %s", r.MaxTokens, caps.MaxTokens, r.Model)
+	}
+	// web_search 等内置工具由服务端处理, 只校验 function 工具.
+	for i, tool := range r.Tools {
+		if known && tool.Type == ToolTypeFunction && !caps.Tools {
+			add(fmt.Sprintf("tools[%d]", i), "function tools not supported by %s", r.Model)
+			break
+		}
+	}
+
+	if len(r.Messages) == 0 {
+		add("prompt", "at least one message is required")
+	}
+	for i, msg := range r.Messages {
+		field := fmt.Sprintf("prompt[%d]", i)
+		switch msg.Role {
+		case ChatMessageRoleSystem, ChatMessageRoleUser:
+			if msg.Content == "" && len(msg.MultiContent) == 0 {
+				add(field+".content", "is empty")
+			}
+		case ChatMessageRoleAssistant:
+		case ChatMessageRoleTool:
+			if msg.ToolCallID == "" {
+				add(field+".tool_call_id", "is required for tool messages")
+			}
+		default:
+			add(field+".role", "unknown role %q", msg.Role)
+		}
+		for _, part := range msg.MultiContent {
+			if part.Type == ChatMessagePartTypeImageURL && known && !caps.Vision {
+				add(field+".content", "image input not supported by %s", r.Model)
+				break
+			}
+		}
+	}
+	// 对话需要以用户消息结束, 工具调用后以工具结果结束.
+	if n := len(r.Messages); n > 0 {
+		if role := r.Messages[n-1].Role; role != ChatMessageRoleUser && role != ChatMessageRoleTool {
+			add(fmt.Sprintf("prompt[%d].role", n-1), "conversation must end with a user or tool message, got %q", role)
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// validateRequest 配置未关闭校验时按 Models 能力表校验请求.
+func (c *Client) validateRequest(request ChatCompletionRequest) error {
+	if c.config.DisableValidation {
+		return nil
+	}
+	return request.validate(c.models())
+}
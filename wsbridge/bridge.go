@@ -0,0 +1,321 @@
+// Package wsbridge 提供 WebSocket 聊天端点, 每个连接即一个会话:
+// 客户端发送的消息转为流式对话调用, 增量内容以文本帧推回, 会话在多轮之间保留历史.
+//
+// 客户端发送的 JSON 帧:
+//
+//	{"type":"message","content":"你好"}   发起一轮对话, 可选 "model" 覆盖默认模型
+//	{"type":"cancel"}                     中止进行中的回复
+//	{"type":"reset"}                      清空会话历史
+//
+// 服务端推送的 JSON 帧 type 为 delta、done、cancelled、reset 或 error.
+package wsbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gtkit/go-zhipu"
+)
+
+const defaultMaxMessageSize = 1 << 20
+
+// 客户端消息类型.
+const (
+	TypeMessage = "message"
+	TypeCancel  = "cancel"
+	TypeReset   = "reset"
+)
+
+// 服务端消息类型, 清空历史后以 TypeReset 确认.
+const (
+	TypeDelta     = "delta"
+	TypeDone      = "done"
+	TypeCancelled = "cancelled"
+	TypeError     = "error"
+)
+
+var (
+	errBusy        = errors.New("a reply is already in progress")
+	errNotRunning  = errors.New("no reply in progress")
+	errEmptyPrompt = errors.New("message content is empty")
+)
+
+// ClientMessage 客户端发送的帧.
+type ClientMessage struct {
+	Type    string `json:"type"`
+	Content string `json:"content,omitempty"`
+	Model   string `json:"model,omitempty"`
+}
+
+// ServerMessage 服务端推送的帧.
+type ServerMessage struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Content string `json:"content,omitempty"`
+	// Meta 在 done 帧中携带本轮的用量信息.
+	Meta  *zhipu.GlmMeta `json:"meta,omitempty"`
+	Error string         `json:"error,omitempty"`
+}
+
+type handler struct {
+	client         zhipu.ChatCompletion[zhipu.ChatCompletionRequest]
+	model          string
+	system         string
+	maxHistory     int
+	maxMessageSize int64
+	checkOrigin    func(r *http.Request) bool
+}
+
+type Option func(*handler)
+
+// WithModel 设置默认模型, 默认为 zhipu.Turbo.
+func WithModel(model string) Option {
+	return func(h *handler) {
+		h.model = model
+	}
+}
+
+// WithSystemPrompt 每轮请求开头附加的系统提示词, 不计入会话历史.
+func WithSystemPrompt(prompt string) Option {
+	return func(h *handler) {
+		h.system = prompt
+	}
+}
+
+// WithMaxHistory 会话最多保留的历史消息条数, 超出时丢弃最早的消息, 0 表示不限制.
+func WithMaxHistory(n int) Option {
+	return func(h *handler) {
+		h.maxHistory = n
+	}
+}
+
+// WithMaxMessageSize 客户端单条消息的最大字节数, 默认为 1 MB.
+func WithMaxMessageSize(n int64) Option {
+	return func(h *handler) {
+		h.maxMessageSize = n
+	}
+}
+
+// WithCheckOrigin 校验握手请求的来源, 返回 false 时拒绝连接. 未设置时接受所有来源.
+func WithCheckOrigin(fn func(r *http.Request) bool) Option {
+	return func(h *handler) {
+		h.checkOrigin = fn
+	}
+}
+
+// NewHandler 返回 WebSocket 端点的 http.Handler.
+func NewHandler(client zhipu.ChatCompletion[zhipu.ChatCompletionRequest], opts ...Option) http.Handler {
+	h := &handler{client: client, model: zhipu.Turbo, maxMessageSize: defaultMaxMessageSize}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.checkOrigin != nil && !h.checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	c, err := upgrade(w, r, h.maxMessageSize)
+	if err != nil {
+		if errors.Is(err, errNotWebSocket) {
+			w.Header().Set("Sec-WebSocket-Version", "13")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	s := &session{handler: h, conn: c}
+	s.serve(r.Context())
+}
+
+// session 一个连接上的会话, history 只包含已完成的轮次.
+type session struct {
+	handler *handler
+	conn    *conn
+
+	mu      sync.Mutex
+	history []zhipu.ChatCompletionMessage
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func (s *session) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		s.wg.Wait()
+		s.conn.close()
+	}()
+
+	for {
+		data, err := s.conn.readMessage()
+		if err != nil {
+			return
+		}
+
+		var msg ClientMessage
+		if err = json.Unmarshal(data, &msg); err != nil {
+			s.send(ServerMessage{Type: TypeError, Error: "invalid message: " + err.Error()})
+			continue
+		}
+
+		switch msg.Type {
+		case TypeMessage:
+			err = s.start(ctx, msg)
+		case TypeCancel:
+			err = s.abort()
+		case TypeReset:
+			s.mu.Lock()
+			s.history = nil
+			s.mu.Unlock()
+			s.send(ServerMessage{Type: TypeReset})
+		default:
+			s.send(ServerMessage{Type: TypeError, Error: "unknown message type: " + msg.Type})
+		}
+		if err != nil {
+			s.send(ServerMessage{Type: TypeError, Error: err.Error()})
+		}
+	}
+}
+
+// start 发起一轮对话, 同一时间只允许一个进行中的回复.
+func (s *session) start(ctx context.Context, msg ClientMessage) error {
+	if strings.TrimSpace(msg.Content) == "" {
+		return errEmptyPrompt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return errBusy
+	}
+
+	model := msg.Model
+	if model == "" {
+		model = s.handler.model
+	}
+	user := zhipu.ChatCompletionMessage{Role: zhipu.ChatMessageRoleUser, Content: msg.Content}
+	messages := make([]zhipu.ChatCompletionMessage, 0, len(s.history)+2)
+	if s.handler.system != "" {
+		messages = append(messages, zhipu.ChatCompletionMessage{Role: zhipu.ChatMessageRoleSystem, Content: s.handler.system})
+	}
+	messages = append(messages, s.history...)
+	messages = append(messages, user)
+
+	turnCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.reply(turnCtx, zhipu.ChatCompletionRequest{Model: model, Messages: messages}, user)
+	}()
+	return nil
+}
+
+func (s *session) abort() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel == nil {
+		return errNotRunning
+	}
+	s.cancel()
+	return nil
+}
+
+// reply 转发一轮回复, 完成时将问答写入历史; 被取消或出错的轮次不计入历史.
+func (s *session) reply(ctx context.Context, request zhipu.ChatCompletionRequest, user zhipu.ChatCompletionMessage) {
+	var (
+		answer strings.Builder
+		done   ServerMessage
+		err    error
+	)
+	defer func() {
+		s.mu.Lock()
+		s.cancel = nil
+		if err == nil {
+			s.appendHistory(user, zhipu.ChatCompletionMessage{Role: zhipu.ChatMessageRoleAssistant, Content: answer.String()})
+		}
+		s.mu.Unlock()
+
+		switch {
+		case ctx.Err() != nil:
+			s.send(ServerMessage{Type: TypeCancelled})
+		case err != nil:
+			s.send(ServerMessage{Type: TypeError, Error: err.Error()})
+		default:
+			done.Type = TypeDone
+			done.Content = answer.String()
+			s.send(done)
+		}
+	}()
+
+	stream, err := s.handler.client.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		return
+	}
+	// 取消时关闭流以唤醒阻塞中的 Recv.
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			stream.Close()
+		case <-finished:
+			stream.Close()
+		}
+	}()
+
+	for {
+		var resp zhipu.GlmChatCompletionStreamResponse
+		resp, err = stream.Recv()
+		if errors.Is(err, io.EOF) {
+			err = nil
+			return
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return
+		}
+
+		var delta strings.Builder
+		for _, choice := range resp.Choices {
+			delta.WriteString(choice.Delta.Content)
+		}
+		if delta.Len() > 0 {
+			answer.WriteString(delta.String())
+			s.send(ServerMessage{Type: TypeDelta, ID: resp.ID, Content: delta.String()})
+		}
+		done.ID = resp.ID
+
+		if resp.Event == "finish" {
+			meta := resp.Meta
+			done.Meta = &meta
+			return
+		}
+	}
+}
+
+// appendHistory 调用方需持有 s.mu.
+func (s *session) appendHistory(messages ...zhipu.ChatCompletionMessage) {
+	s.history = append(s.history, messages...)
+	if limit := s.handler.maxHistory; limit > 0 && len(s.history) > limit {
+		s.history = append([]zhipu.ChatCompletionMessage(nil), s.history[len(s.history)-limit:]...)
+	}
+}
+
+func (s *session) send(msg ServerMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	_ = s.conn.writeText(data)
+}
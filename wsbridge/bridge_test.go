@@ -0,0 +1,197 @@
+package wsbridge_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gtkit/go-zhipu"
+	"github.com/gtkit/go-zhipu/wsbridge"
+)
+
+func newBridge(t *testing.T) *httptest.Server {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request zhipu.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/glm-4/sse-invoke":
+			// 回复中带上收到的消息条数, 用于验证历史.
+			fmt.Fprintf(w, "event:add\nid:t1\ndata:%d\n\n", len(request.Messages))
+			fmt.Fprint(w, "event:finish\nid:t1\ndata:msgs\nmeta:{\"usage\":{\"total_tokens\":3}}\n\n")
+		case "/slow/sse-invoke":
+			fmt.Fprint(w, "event:add\nid:t2\ndata:partial\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	config := zhipu.DefaultConfig("token")
+	config.BaseURL = upstream.URL + "/"
+	server := httptest.NewServer(wsbridge.NewHandler(zhipu.NewClientWithConfig(config),
+		wsbridge.WithModel(zhipu.GLM4), wsbridge.WithSystemPrompt("be brief")))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// client 测试用的最小 WebSocket 客户端.
+type client struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dial(t *testing.T, server *httptest.Server) *client {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected handshake response: %d %v", resp.StatusCode, resp.Header)
+	}
+	return &client{t: t, conn: conn, reader: reader}
+}
+
+func (c *client) send(msg wsbridge.ClientMessage) {
+	c.t.Helper()
+	payload, _ := json.Marshal(msg)
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x81, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) recv() wsbridge.ServerMessage {
+	c.t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			c.t.Fatal(err)
+		}
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	if header[0]&0x0F != 0x1 {
+		c.t.Fatalf("unexpected opcode %x", header[0]&0x0F)
+	}
+	var msg wsbridge.ServerMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// reply 跳过增量帧, 返回本轮的结束帧.
+func (c *client) reply() wsbridge.ServerMessage {
+	c.t.Helper()
+	for {
+		if msg := c.recv(); msg.Type != wsbridge.TypeDelta {
+			return msg
+		}
+	}
+}
+
+func TestSessionKeepsHistory(t *testing.T) {
+	c := dial(t, newBridge(t))
+
+	for _, want := range []string{"2msgs", "4msgs"} {
+		c.send(wsbridge.ClientMessage{Type: wsbridge.TypeMessage, Content: "hi"})
+		var deltas strings.Builder
+		for {
+			msg := c.recv()
+			if msg.Type == wsbridge.TypeDelta {
+				deltas.WriteString(msg.Content)
+				continue
+			}
+			if msg.Type != wsbridge.TypeDone || msg.Content != want || deltas.String() != want ||
+				msg.Meta == nil || msg.Meta.Usage.TotalTokens != 3 {
+				t.Fatalf("unexpected reply %+v, deltas %q", msg, deltas.String())
+			}
+			break
+		}
+	}
+
+	c.send(wsbridge.ClientMessage{Type: wsbridge.TypeReset})
+	if msg := c.recv(); msg.Type != wsbridge.TypeReset {
+		t.Fatalf("unexpected reset reply %+v", msg)
+	}
+	c.send(wsbridge.ClientMessage{Type: wsbridge.TypeMessage, Content: "hi"})
+	if msg := c.reply(); msg.Content != "2msgs" {
+		t.Fatalf("history not reset: %+v", msg)
+	}
+}
+
+func TestSessionCancel(t *testing.T) {
+	c := dial(t, newBridge(t))
+
+	c.send(wsbridge.ClientMessage{Type: wsbridge.TypeMessage, Content: "hi", Model: "slow"})
+	if msg := c.recv(); msg.Type != wsbridge.TypeDelta || msg.Content != "partial" {
+		t.Fatalf("unexpected delta %+v", msg)
+	}
+
+	c.send(wsbridge.ClientMessage{Type: wsbridge.TypeMessage, Content: "again"})
+	if msg := c.recv(); msg.Type != wsbridge.TypeError {
+		t.Fatalf("expected busy error, got %+v", msg)
+	}
+
+	c.send(wsbridge.ClientMessage{Type: wsbridge.TypeCancel})
+	if msg := c.recv(); msg.Type != wsbridge.TypeCancelled {
+		t.Fatalf("expected cancelled, got %+v", msg)
+	}
+
+	// 被取消的轮次不计入历史.
+	c.send(wsbridge.ClientMessage{Type: wsbridge.TypeMessage, Content: "hi"})
+	if msg := c.reply(); msg.Type != wsbridge.TypeDone || msg.Content != "2msgs" {
+		t.Fatalf("unexpected reply %+v", msg)
+	}
+}
+
+func TestRejectsPlainHTTP(t *testing.T) {
+	resp, err := http.Get(newBridge(t).URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,232 @@
+package wsbridge
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // required by RFC 6455 handshake
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// RFC 6455 定义的常量.
+const (
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+
+	closeNormal       = 1000
+	closeProtocol     = 1002
+	closeTooLarge     = 1009
+	maxControlPayload = 125
+)
+
+var (
+	errNotWebSocket    = errors.New("wsbridge: not a websocket handshake")
+	errMessageTooLarge = errors.New("wsbridge: message too large")
+	errProtocol        = errors.New("wsbridge: protocol error")
+)
+
+// conn 服务端 WebSocket 连接, 只实现会话所需的文本消息、ping/pong 与关闭.
+type conn struct {
+	netConn net.Conn
+	reader  *bufio.Reader
+
+	writeMu sync.Mutex
+
+	maxMessageSize int64
+}
+
+// upgrade 完成握手并接管底层连接.
+func upgrade(w http.ResponseWriter, r *http.Request, maxMessageSize int64) (*conn, error) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" ||
+		r.Header.Get("Sec-WebSocket-Key") == "" {
+		return nil, errNotWebSocket
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("wsbridge: response writer does not support hijacking")
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + websocketGUID)) //nolint:gosec // required by RFC 6455
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+	_, err = fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", accept)
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	return &conn{netConn: netConn, reader: rw.Reader, maxMessageSize: maxMessageSize}, nil
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readMessage 读取一条完整的文本或二进制消息, 期间自动回复 ping 并处理关闭帧.
+func (c *conn) readMessage() ([]byte, error) {
+	var (
+		message []byte
+		started bool
+	)
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err = c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			_ = c.writeFrame(opClose, payload)
+			return nil, io.EOF
+		case opText, opBinary:
+			if started {
+				return nil, c.fail(closeProtocol, errProtocol)
+			}
+			started = true
+		case opContinuation:
+			if !started {
+				return nil, c.fail(closeProtocol, errProtocol)
+			}
+		default:
+			return nil, c.fail(closeProtocol, errProtocol)
+		}
+
+		if int64(len(message)+len(payload)) > c.maxMessageSize {
+			return nil, c.fail(closeTooLarge, errMessageTooLarge)
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+func (c *conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7F)
+
+	if header[0]&0x70 != 0 || !masked {
+		// 不支持扩展, 客户端发送的帧必须带掩码.
+		return fin, opcode, nil, c.fail(closeProtocol, errProtocol)
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]) & (1<<63 - 1))
+	}
+	if opcode >= opClose && (length > maxControlPayload || !fin) {
+		return fin, opcode, nil, c.fail(closeProtocol, errProtocol)
+	}
+	if length > c.maxMessageSize {
+		return fin, opcode, nil, c.fail(closeTooLarge, errMessageTooLarge)
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+func (c *conn) writeText(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// writeFrame 写入一个不分片、不带掩码的帧, 可以被多个 goroutine 并发调用.
+func (c *conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n <= maxControlPayload:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	if _, err := c.netConn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// fail 发送关闭帧并返回 err.
+func (c *conn) fail(code uint16, err error) error {
+	_ = c.writeClose(code)
+	return err
+}
+
+func (c *conn) writeClose(code uint16) error {
+	return c.writeFrame(opClose, binary.BigEndian.AppendUint16(nil, code))
+}
+
+func (c *conn) close() error {
+	_ = c.writeClose(closeNormal)
+	return c.netConn.Close()
+}